@@ -0,0 +1,388 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// AreaChange records one area assignment made by Apply.
+type AreaChange struct {
+	Kind     Kind
+	Name     string // e.g. "Customer", "Customer.CustNum", "Item.ItemImage"
+	Line     int    // line of the AREA or LOB-AREA attribute, or of the statement if Inserted
+	From     string // "" if Inserted
+	To       string
+	Inserted bool // the statement had no AREA or LOB-AREA line and one was added
+}
+
+// ApplyResult describes what Apply did.
+type ApplyResult struct {
+	// Changes lists every area that was assigned, in source order,
+	// including those whose value did not change.
+	Changes []AreaChange
+
+	// ByteCount is the regenerated trailer byte count, or -1 if the input
+	// had none.
+	ByteCount int64
+
+	// Unmapped lists the areas the area map was applied to that are
+	// neither a key nor a value of it, in the order they were found.
+	Unmapped []string
+}
+
+// Apply reads a .df from in, replaces the area of every table, index and LOB
+// field according to rules and writes the result to out. A table, index or
+// LOB field without an AREA or LOB-AREA line gets one inserted, as OpenEdge
+// would otherwise put it in the Schema Area. The .df is streamed
+// one construct at a time. Names are decoded from the input codepage (see
+// Options.Codepage) before they are matched against rules, and the output is
+// written in that same codepage. A trailing byte count is recalculated for
+// the new content.
+func Apply(ctx context.Context, in io.Reader, rules *SchemaFixerRules, out io.Writer, opts Options) (*ApplyResult, error) {
+	return apply(ctx, in, rules, out, opts, nil)
+}
+
+// apply is Apply, recording how the areas of the constructs x asks about
+// are resolved. x may be nil.
+func apply(ctx context.Context, in io.Reader, rules *SchemaFixerRules, out io.Writer, opts Options, x *explainer) (*ApplyResult, error) {
+	log := opts.Logger
+	log.Debug().
+		Int("tables", len(rules.Tables)).
+		Str("defaultTable", rules.Defaults.Table).
+		Str("defaultIndex", rules.Defaults.Index).
+		Str("defaultLob", rules.Defaults.Lob).
+		Msg("apply started")
+
+	if opts.MapOnly && len(rules.AreaMap) == 0 {
+		return nil, fmt.Errorf("the rules have no areaMap")
+	}
+	rules, err := rules.conditions()
+	if err != nil {
+		return nil, err
+	}
+
+	name, cp, err := codepage(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("codepage", name).Msg("input codepage")
+
+	a := &applier{
+		rules:    rules,
+		opts:     opts,
+		file:     inputName(in),
+		x:        x,
+		res:      &ApplyResult{ByteCount: -1},
+		enc:      df.NewEncoder(out),
+		unmapped: map[string]bool{},
+		hold:     rules.needsTableAggregates(),
+	}
+	a.enc.EOL = opts.EOL
+	a.enc.Encoding = cp
+
+	err = decode(ctx, in, cp, newChecker(in, opts), a.node)
+	if err == nil {
+		err = a.flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().Int("changes", len(a.res.Changes)).Msg("apply complete")
+	return a.res, nil
+}
+
+// applier assigns the areas of one .df as it is decoded.
+type applier struct {
+	rules *SchemaFixerRules
+	opts  Options
+	file  string
+	x     *explainer // may be nil
+	res   *ApplyResult
+	enc   *df.Encoder
+
+	unmapped map[string]bool // lowercase areas reported as not in the area map
+
+	// table describes the table being processed, for conditional rules.
+	// When a rule tests a table's fields or indexes (hold), the table's
+	// statements are held back in pending until the next table, sequence
+	// or trailer, so the table's own AREA is decided knowing all of them.
+	table   *TableInfo
+	pending []df.Node
+	hold    bool
+}
+
+// report reports a diagnostic at pos.
+func (a *applier) report(sev Severity, pos df.Pos, format string, args ...any) {
+	report(a.opts, Diagnostic{Severity: sev, File: a.file, Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// node takes one decoded node, handling it right away or holding it back
+// with the rest of its table.
+func (a *applier) node(n df.Node) error {
+	switch n := n.(type) {
+	case *df.Table:
+		if err := a.flush(); err != nil {
+			return err
+		}
+		a.table = newTableInfo(n)
+	case *df.Sequence, *df.Trailer:
+		if err := a.flush(); err != nil {
+			return err
+		}
+		a.table = nil
+	default:
+		if a.table != nil {
+			a.table.add(n)
+		}
+	}
+	if a.hold && a.table != nil {
+		a.pending = append(a.pending, n)
+		return nil
+	}
+	return a.handle(n)
+}
+
+// flush handles the nodes held back.
+func (a *applier) flush() error {
+	for _, n := range a.pending {
+		if err := a.handle(n); err != nil {
+			return err
+		}
+	}
+	a.pending = nil
+	return nil
+}
+
+// handle assigns the area of n, if it has one, and writes it.
+func (a *applier) handle(n df.Node) error {
+	log := a.opts.Logger
+	rules := a.rules
+	switch n := n.(type) {
+	case *df.Table:
+		log.Debug().Str("table", n.Name).Msg("parsing TABLE")
+		if _, err := rules.Group(n.Name); err != nil {
+			a.report(SeverityError, n.Pos(), "%s", err)
+		}
+		t := a.owner(n.Name)
+		cur := currentArea(n.Attr("AREA"))
+		tr := a.x.trace(KindTable, n.Name, "", n.Pos().Line, cur)
+		d, err := a.place(KindTable, n.Name, n.Pos(), cur, tr, func(cur string) (decision, error) {
+			t.Area = cur
+			return rules.tableDecision(t, tr)
+		})
+		if err != nil {
+			return err
+		}
+		a.set(&n.Stmt, "AREA", 0, KindTable, n.Name, d, tr)
+		log.Debug().Str("table", n.Name).Str("area", d.area).Msg("TABLE area replaced")
+
+	case *df.Index:
+		log.Debug().Str("index", n.Name).Str("table", n.Table).Msg("parsing INDEX")
+		ix := indexInfo(n)
+		tr := a.x.trace(KindIndex, n.Table, n.Name, n.Pos().Line, ix.Area)
+		d, err := a.place(KindIndex, n.Table+"."+n.Name, n.Pos(), ix.Area, tr, func(cur string) (decision, error) {
+			ix.Area = cur
+			return rules.indexDecision(a.owner(n.Table), ix, tr)
+		})
+		if err != nil {
+			return err
+		}
+		a.set(&n.Stmt, "AREA", 0, KindIndex, n.Table+"."+n.Name, d, tr)
+		log.Debug().Str("index", n.Name).Str("table", n.Table).Str("area", d.area).Msg("INDEX area replaced")
+
+	case *df.Field:
+		log.Debug().Str("field", n.Name).Str("table", n.Table).Msg("parsing FIELD")
+		if n.IsLob() || n.HasAttr("LOB-AREA") {
+			f := fieldInfo(n)
+			tr := a.x.trace(KindLob, n.Table, n.Name, n.Pos().Line, f.LobArea)
+			d, err := a.place(KindLob, n.Table+"."+n.Name, n.Pos(), f.LobArea, tr, func(cur string) (decision, error) {
+				f.LobArea = cur
+				return rules.lobDecision(a.owner(n.Table), f, tr)
+			})
+			if err != nil {
+				return err
+			}
+			a.set(&n.Stmt, "LOB-AREA", lobAreaAt(n), KindLob, n.Table+"."+n.Name, d, tr)
+			log.Debug().Str("field", n.Name).Str("table", n.Table).Str("area", d.area).Msg("LOB-AREA replaced")
+		}
+
+	case *df.Update, *df.Drop, *df.Rename:
+		// Statements of an incremental .df change existing objects,
+		// whose areas can only be moved with proutil tablemove.
+		log.Debug().Int("line", n.Pos().Line).Msg("delta statement left unchanged")
+
+	case *df.Trailer:
+		return encodeTrailer(a.enc, n, &a.res.ByteCount, log)
+	}
+	return a.enc.Encode(n)
+}
+
+// owner returns the TableInfo for a field or index of tableName.
+func (a *applier) owner(tableName string) *TableInfo {
+	if a.table != nil && strings.EqualFold(a.table.Name, tableName) {
+		return a.table
+	}
+	return &TableInfo{Name: tableName}
+}
+
+// place returns the area for the construct kind name at pos, whose current
+// area is cur. decide applies the rules to the construct as if it were in
+// the area passed to it. The area map is applied to cur before, or to the
+// decided area after, the rules, or alone with opts.MapOnly. Keep leaves
+// the construct as it is. Renames by the area map are recorded in tr.
+func (a *applier) place(kind Kind, name string, pos df.Pos, cur string, tr *Explanation, decide func(cur string) (decision, error)) (decision, error) {
+	switch {
+	case a.opts.MapOnly:
+	case len(a.rules.AreaMap) == 0:
+		return decide(cur)
+	case a.rules.mapLast():
+		d, err := decide(cur)
+		if err != nil {
+			return d, err
+		}
+		if d.area != Keep {
+			if mapped := a.mapArea(d.area, kind, name, pos); mapped.area != d.area {
+				tr.mapped(d.area, mapped)
+				return mapped, nil
+			}
+			return d, nil
+		}
+	default:
+		mapped := a.mapArea(cur, kind, name, pos)
+		if mapped.area != cur {
+			tr.mapped(cur, mapped)
+		}
+		d, err := decide(mapped.area)
+		if err != nil || d.area != Keep {
+			return d, err
+		}
+		if mapped.area != cur {
+			return mapped, nil
+		}
+		return decision{area: Keep}, nil
+	}
+	if mapped := a.mapArea(cur, kind, name, pos); mapped.area != cur {
+		tr.mapped(cur, mapped)
+		return mapped, nil
+	}
+	return decision{area: Keep}, nil
+}
+
+// mapArea translates area, of the construct kind name at pos, through the
+// area map, reporting each area that is not in it once.
+func (a *applier) mapArea(area string, kind Kind, name string, pos df.Pos) decision {
+	mapped, ok := a.rules.mapArea(area)
+	if key := strings.ToLower(area); !ok && !a.unmapped[key] {
+		a.unmapped[key] = true
+		a.res.Unmapped = append(a.res.Unmapped, area)
+		a.report(SeverityWarning, pos, "area %q of %s %s is not in the area map", area, strings.ToLower(kind.String()), name)
+	}
+	return mapped
+}
+
+// set assigns the area of d to the keyword attribute of s, inserting the
+// attribute at index at if s has none. Keep, and constructs outside
+// opts.OnlyFrom, leave s as it is. An area that is not in the areas catalog
+// is reported as a warning with the rule that chose it; an empty area,
+// which OpenEdge cannot load, as an error, and s is left as it is. The area
+// s ends up in is recorded in tr.
+func (a *applier) set(s *df.Stmt, keyword string, at int, kind Kind, name string, d decision, tr *Explanation) {
+	area := d.area
+	if area == Keep || !relocates(a.opts, currentArea(s.Attr(keyword))) {
+		if tr != nil {
+			tr.Area = tr.Current
+		}
+		return
+	}
+	if sev, msg := a.rules.checkArea(d); msg != "" {
+		if sev == SeverityError {
+			msg += fmt.Sprintf("; its %s is left as it is", keyword)
+		}
+		a.report(sev, s.Pos(), "%s %s: %s", strings.ToLower(kind.String()), name, msg)
+		if sev == SeverityError {
+			if tr != nil {
+				tr.Area = tr.Current
+			}
+			return
+		}
+	}
+	if tr != nil {
+		tr.Area = area
+	}
+	if attr := s.Attr(keyword); attr != nil {
+		a.res.Changes = append(a.res.Changes, AreaChange{Kind: kind, Name: name, Line: attr.Pos().Line, From: attr.Value(), To: area})
+		attr.SetValue(area)
+		return
+	}
+	s.InsertAttr(at, "  "+keyword+" "+df.Quote(area))
+	a.res.Changes = append(a.res.Changes, AreaChange{Kind: kind, Name: name, Line: s.Pos().Line, To: area, Inserted: true})
+	a.report(SeverityInfo, s.Pos(), "missing %s of %s %s inserted: %q", keyword, strings.ToLower(kind.String()), name, area)
+}
+
+// relocates reports whether Apply may move a construct currently in area.
+func relocates(opts Options, area string) bool {
+	return len(opts.OnlyFrom) == 0 || slices.ContainsFunc(opts.OnlyFrom, func(from string) bool { return strings.EqualFold(from, area) })
+}
+
+// lobAreaAt returns where a missing LOB-AREA goes, following the attribute
+// order of a Data Dictionary dump: after POSITION, before the LOB sizes and
+// ORDER. AREA lines of tables and indexes go directly after the header.
+func lobAreaAt(f *df.Field) int {
+	if i := f.AttrIndex("LOB-BYTES", "LOB-SIZE", "ORDER"); i >= 0 {
+		return i
+	}
+	return len(f.Attrs)
+}
+
+// newTableInfo returns the TableInfo of t, before its fields and indexes
+// are added.
+func newTableInfo(t *df.Table) *TableInfo {
+	return &TableInfo{Name: t.Name, DumpName: t.AttrValue("DUMP-NAME"), Area: currentArea(t.Attr("AREA"))}
+}
+
+// add counts n if it is a field or index of t.
+func (t *TableInfo) add(n df.Node) {
+	switch n := n.(type) {
+	case *df.Field:
+		if strings.EqualFold(n.Table, t.Name) {
+			t.Fields++
+			if n.IsLob() {
+				t.Lobs++
+			}
+		}
+	case *df.Index:
+		if strings.EqualFold(n.Table, t.Name) {
+			t.Indexes++
+		}
+	}
+}
+
+// indexInfo returns the IndexInfo of ix.
+func indexInfo(ix *df.Index) *IndexInfo {
+	info := &IndexInfo{Table: ix.Table, Name: ix.Name, Area: currentArea(ix.Attr("AREA")), Unique: ix.Unique(), Primary: ix.Primary(), Word: ix.Word()}
+	for _, a := range ix.Attrs {
+		if a.Is("INDEX-FIELD") {
+			info.Fields++
+		}
+	}
+	return info
+}
+
+// fieldInfo returns the FieldInfo of f. LOB-BYTES is the exact size of a
+// LOB; LOB-SIZE is the size as it was declared, e.g. 100M.
+func fieldInfo(f *df.Field) *FieldInfo {
+	info := &FieldInfo{Table: f.Table, Name: f.Name, Type: strings.ToLower(f.DataType), LobArea: currentArea(f.Attr("LOB-AREA"))}
+	if n, err := strconv.ParseInt(f.AttrValue("LOB-BYTES"), 10, 64); err == nil {
+		info.LobSize = n
+	} else if n, err := parseSize(f.AttrValue("LOB-SIZE")); err == nil {
+		info.LobSize = n
+	}
+	return info
+}
@@ -0,0 +1,164 @@
+package schemafixer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestApply(t *testing.T) {
+	var out, logs bytes.Buffer
+	opts := Options{Logger: zerolog.New(&logs).Level(zerolog.DebugLevel)}
+
+	res, err := Apply(context.Background(), strings.NewReader(testDF), mustRules(t, testRules), &out, opts)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	body := strings.ReplaceAll(testDF, "Schema Area", "@")
+	body = strings.Replace(body, "@", "data", 1)
+	body = strings.Replace(body, "@", "index1", 1)
+	body = strings.Replace(body, "@", "DataArea", 1)
+	body = strings.Replace(body, "@", "lob1", 1)
+	body = strings.TrimSuffix(body, "0000000000\n")
+	want := body + fmt.Sprintf("%010d\n", trailerOffset(body))
+	if out.String() != want {
+		t.Errorf("Apply() output mismatch\ngot:  %q\nwant: %q", out.String(), want)
+	}
+
+	if res.ByteCount != int64(trailerOffset(body)) {
+		t.Errorf("ByteCount = %d, want %d", res.ByteCount, trailerOffset(body))
+	}
+	wantChanges := []AreaChange{
+		{Kind: KindTable, Name: "Customer", Line: 2, From: "Schema Area", To: "data"},
+		{Kind: KindIndex, Name: "Customer.CustNum", Line: 5, From: "Schema Area", To: "index1"},
+		{Kind: KindTable, Name: "Item", Line: 9, From: "Schema Area", To: "DataArea"},
+		{Kind: KindLob, Name: "Item.ItemImage", Line: 12, From: "Schema Area", To: "lob1"},
+	}
+	if len(res.Changes) != len(wantChanges) {
+		t.Fatalf("got %d changes, want %d: %+v", len(res.Changes), len(wantChanges), res.Changes)
+	}
+	for i, c := range wantChanges {
+		if res.Changes[i] != c {
+			t.Errorf("change %d = %+v, want %+v", i, res.Changes[i], c)
+		}
+	}
+
+	if !strings.Contains(logs.String(), "TABLE area replaced") {
+		t.Errorf("expected debug messages on the injected logger, got %q", logs.String())
+	}
+}
+
+func TestApply_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Apply(ctx, strings.NewReader(testDF), mustRules(t, testRules), &bytes.Buffer{}, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Apply() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestApply_Codepage(t *testing.T) {
+	// A table named "Größe" in an ISO8859-1 .df; the rule is UTF-8.
+	src := "ADD TABLE \"GR\xd6\xdfE\"\n  AREA \"Schema Area\"\n\n.\nPSC\ncpstream=ISO8859-1\n.\n0000000000\n"
+	rules := mustRules(t, "schemafixer:\n  version: 1.0\n  tables:\n    - name: größe\n      area: Daten\n")
+
+	var out bytes.Buffer
+	res, err := Apply(context.Background(), strings.NewReader(src), rules, &out, Options{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(res.Changes) != 1 || res.Changes[0].Name != "GRÖßE" || res.Changes[0].To != "Daten" {
+		t.Errorf("unexpected changes: %+v", res.Changes)
+	}
+	if !strings.HasPrefix(out.String(), "ADD TABLE \"GR\xd6\xdfE\"\n  AREA \"Daten\"\n") {
+		t.Errorf("output not written in ISO8859-1: %q", out.String())
+	}
+}
+
+func TestApply_Delta(t *testing.T) {
+	delta := "UPDATE TABLE \"Customer\"\n" +
+		"  AREA \"Schema Area\"\n" +
+		"\n" +
+		"ADD INDEX \"Name\" ON \"Customer\" \n" +
+		"  AREA \"Schema Area\"\n" +
+		"\n" +
+		"DROP INDEX \"CustNum\" ON \"Customer\"\n" +
+		"\n"
+
+	var out bytes.Buffer
+	res, err := Apply(context.Background(), strings.NewReader(delta), mustRules(t, testRules), &out, Options{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(res.Changes) != 1 || res.Changes[0].Name != "Customer.Name" || res.Changes[0].To != "IndexArea" {
+		t.Errorf("unexpected changes: %+v", res.Changes)
+	}
+	want := strings.Replace(delta, "ON \"Customer\" \n  AREA \"Schema Area\"", "ON \"Customer\" \n  AREA \"IndexArea\"", 1)
+	if out.String() != want {
+		t.Errorf("Apply() output mismatch, UPDATE block must be left untouched\ngot:  %q\nwant: %q", out.String(), want)
+	}
+}
+
+func TestApply_InsertsMissingAreas(t *testing.T) {
+	src := "ADD TABLE \"Customer\"\n" +
+		"  DUMP-NAME \"customer\"\n" +
+		"\n" +
+		"ADD FIELD \"Photo\" OF \"Customer\" AS blob \n" +
+		"  POSITION 3\n" +
+		"  LOB-SIZE 1M\n" +
+		"  ORDER 20\n" +
+		"\n" +
+		"ADD FIELD \"Name\" OF \"Customer\" AS character \n" +
+		"  ORDER 10\n" +
+		"\n" +
+		"ADD INDEX \"CustNum\" ON \"Customer\" \n" +
+		"  UNIQUE\n" +
+		"\n"
+
+	var out bytes.Buffer
+	res, err := Apply(context.Background(), strings.NewReader(src), mustRules(t, testRules), &out, Options{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := "ADD TABLE \"Customer\"\n" +
+		"  AREA \"data\"\n" +
+		"  DUMP-NAME \"customer\"\n" +
+		"\n" +
+		"ADD FIELD \"Photo\" OF \"Customer\" AS blob \n" +
+		"  POSITION 3\n" +
+		"  LOB-AREA \"LobArea\"\n" +
+		"  LOB-SIZE 1M\n" +
+		"  ORDER 20\n" +
+		"\n" +
+		"ADD FIELD \"Name\" OF \"Customer\" AS character \n" +
+		"  ORDER 10\n" +
+		"\n" +
+		"ADD INDEX \"CustNum\" ON \"Customer\" \n" +
+		"  AREA \"index1\"\n" +
+		"  UNIQUE\n" +
+		"\n"
+	if out.String() != want {
+		t.Errorf("Apply() output mismatch\ngot:  %q\nwant: %q", out.String(), want)
+	}
+
+	wantChanges := []AreaChange{
+		{Kind: KindTable, Name: "Customer", Line: 1, To: "data", Inserted: true},
+		{Kind: KindLob, Name: "Customer.Photo", Line: 4, To: "LobArea", Inserted: true},
+		{Kind: KindIndex, Name: "Customer.CustNum", Line: 12, To: "index1", Inserted: true},
+	}
+	if len(res.Changes) != len(wantChanges) {
+		t.Fatalf("got changes %+v, want %+v", res.Changes, wantChanges)
+	}
+	for i, c := range wantChanges {
+		if res.Changes[i] != c {
+			t.Errorf("change %d = %+v, want %+v", i, res.Changes[i], c)
+		}
+	}
+}
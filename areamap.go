@@ -0,0 +1,76 @@
+package schemafixer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ── Area map ──────────────────────────────────────────────────────────────────
+//
+// The areaMap section renames areas one to one, whatever the construct:
+//
+//	schemafixer:
+//	  areaMap:
+//	    Data Area: DataArea
+//	    Index Area: IndexArea
+//
+// With mapOrder first (the default) the current area of a construct is
+// translated before the rules are applied, so from rules and @keep see the
+// new name. With mapOrder last the area the rules choose is translated
+// instead. Options.MapOnly applies the map alone.
+
+// Values of SchemaFixerRules.MapOrder.
+const (
+	MapFirst = "first"
+	MapLast  = "last"
+)
+
+// mapArea returns the area the area map translates area to, with the
+// location of its entry. ok is false if area is neither a key nor a value of
+// the map; an area that is a value is returned as it is. Keys are compared
+// case-insensitively.
+func (r *SchemaFixerRules) mapArea(area string) (mapped decision, ok bool) {
+	for k, v := range r.AreaMap {
+		if strings.EqualFold(k, area) && v != "" {
+			return decision{v, r.pos["areaMap."+k]}, true
+		}
+	}
+	for _, v := range r.AreaMap {
+		if strings.EqualFold(v, area) {
+			return decision{area: area}, true
+		}
+	}
+	return decision{area: area}, false
+}
+
+// mapLast reports whether the area map translates the areas the rules
+// choose rather than the current areas.
+func (r *SchemaFixerRules) mapLast() bool {
+	return strings.EqualFold(r.MapOrder, MapLast)
+}
+
+// validateAreaMap checks that the keys of the area map differ in more than
+// case and that mapOrder is first or last.
+func (r *SchemaFixerRules) validateAreaMap() error {
+	switch strings.ToLower(r.MapOrder) {
+	case "", MapFirst, MapLast:
+	default:
+		return fmt.Errorf("mapOrder %q: must be %s or %s", r.MapOrder, MapFirst, MapLast)
+	}
+	return validateMapKeys(r.AreaMap)
+}
+
+// validateMapKeys checks that the keys of an area map differ in more than
+// case.
+func validateMapKeys(m map[string]string) error {
+	seen := map[string]string{}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if prev, ok := seen[strings.ToLower(k)]; ok {
+			return fmt.Errorf("areaMap: %q and %q are the same area", prev, k)
+		}
+		seen[strings.ToLower(k)] = k
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestApply_AreaMap(t *testing.T) {
+	src := "ADD TABLE \"Order\"\n  AREA \"Data Area\"\n\n" +
+		"ADD TABLE \"Item\"\n  AREA \"Data Area\"\n\n" +
+		"ADD TABLE \"Bin\"\n\n" +
+		"ADD INDEX \"OrderNum\" ON \"Order\" \n  AREA \"Index Area\"\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Order\" \n  AREA \"Other Area\"\n\n"
+	apply := func(rules *SchemaFixerRules, opts Options) (string, []string) {
+		t.Helper()
+		var diags []string
+		opts.Report = func(d Diagnostic) {
+			if d.Severity == SeverityWarning {
+				diags = append(diags, d.String())
+			}
+		}
+		got, _ := applyDF(t, src, rules, opts)
+		return got, diags
+	}
+	const areaMap = `
+  areaMap:
+    data area: DataArea
+    Index Area: IndexArea
+`
+
+	// The map alone renames areas and leaves the others.
+	rules := mustRules(t, "schemafixer:\n  defaults:\n    table: Ignored\n"+areaMap)
+	got, diags := apply(rules, Options{MapOnly: true})
+	if want := "Order=DataArea Item=DataArea Order.OrderNum=IndexArea"; got != want {
+		t.Errorf("MapOnly changes = %s\nwant %s", got, want)
+	}
+	wantDiags := []string{
+		`7:1: warning: area "Schema Area" of table Bin is not in the area map`,
+		`12:1: warning: area "Other Area" of index Order.CustNum is not in the area map`,
+	}
+	if strings.Join(diags, "\n") != strings.Join(wantDiags, "\n") {
+		t.Errorf("MapOnly diagnostics:\n%s\nwant:\n%s", strings.Join(diags, "\n"), strings.Join(wantDiags, "\n"))
+	}
+
+	// Mapped first, the rules see the new names.
+	rules = mustRules(t, `schemafixer:
+  defaults:
+    table: "@keep"
+    index: "@keep"
+  tables:
+    - name: item
+      area: ItemArea
+  rules:
+    - from: IndexArea
+      to: NewIdx
+`+areaMap)
+	if got, _ := apply(rules, Options{}); got != "Order=DataArea Item=ItemArea Order.OrderNum=NewIdx" {
+		t.Errorf("first changes = %s", got)
+	}
+
+	// Mapped last, the areas the rules choose are renamed.
+	rules = mustRules(t, `schemafixer:
+  mapOrder: last
+  defaults:
+    table: Data Area
+    index: "@keep"
+  tables:
+    - name: item
+      area: ItemArea
+`+areaMap)
+	if got, diags := apply(rules, Options{}); got != "Order=DataArea Item=ItemArea Bin=DataArea Order.OrderNum=IndexArea" || len(diags) != 2 {
+		t.Errorf("last changes = %s, diagnostics %q", got, diags)
+	}
+
+	if _, err := Apply(context.Background(), strings.NewReader(src), mustRules(t, testRules), io.Discard, Options{MapOnly: true}); err == nil {
+		t.Error("Apply(MapOnly) without an areaMap succeeded")
+	}
+	// An environment merges its area map key by key.
+	rules = mustRules(t, "schemafixer:"+areaMap+"  environments:\n    prod:\n      areaMap:\n        Data Area: \"\"\n        Other Area: OtherArea\n")
+	prod, err := rules.Environment("prod")
+	if err != nil {
+		t.Fatalf("Environment() error = %v", err)
+	}
+	if got := fmt.Sprint(prod.AreaMap); got != "map[Index Area:IndexArea Other Area:OtherArea]" {
+		t.Errorf("prod areaMap = %s", got)
+	}
+
+	if _, err := ReadRules(strings.NewReader("schemafixer:\n  mapOrder: middle\n")); err == nil {
+		t.Error("ReadRules() accepted mapOrder middle")
+	}
+}
@@ -1,49 +1,25 @@
 package commands
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"os"
-	"regexp"
-	"runtime"
-	"strings"
 
+	"github.com/bfv/schemafixer"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
-)
-
-// Regular expressions for .df construct detection and area replacement.
-var (
-	reAddTable    = regexp.MustCompile(`(?i)^ADD TABLE "([^"]+)"`)
-	reAddField    = regexp.MustCompile(`(?i)^ADD FIELD "([^"]+)" OF "([^"]+)"`)
-	reAddIndex    = regexp.MustCompile(`(?i)^ADD INDEX "([^"]+)" ON "([^"]+)"`)
-	reAddSequence = regexp.MustCompile(`(?i)^ADD SEQUENCE `)
-	reChecksum    = regexp.MustCompile(`^\d{10}$`)
-	reArea        = regexp.MustCompile(`^(  AREA ")([^"]+)(".*$)`)
-	reLobArea     = regexp.MustCompile(`^(  LOB-AREA ")([^"]+)(".*$)`)
-)
-
-// parseState tracks which kind of .df construct is currently being parsed.
-type parseState int
-
-const (
-	stateNone parseState = iota
-	stateTable
-	stateField
-	stateIndex
-	stateOther // sequences and unrecognised constructs — pass through unchanged
 )
 
 // NewApplyCmd builds and returns the 'apply' cobra command.
 func NewApplyCmd() *cobra.Command {
-	var outputFile string
+	var outputFile, eol, backup, env, codepage string
+	var inPlace, mapOnly bool
+	var vars map[string]string
+	var onlyFrom []string
 
 	cmd := &cobra.Command{
-		Use:   "apply <schema.df> <rules.yaml>",
+		Use:   "apply <schema.df|-> <rules.yaml>",
 		Short: "Apply area rules to a .df schema file",
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -51,225 +27,86 @@ func NewApplyCmd() *cobra.Command {
 			if err := viper.BindPFlag("output", cmd.Flags().Lookup("output")); err != nil {
 				return err
 			}
-			return runApply(args[0], args[1], viper.GetString("output"))
+			lineEnding, err := parseEOL(eol)
+			if err != nil {
+				return err
+			}
+			outputPath := viper.GetString("output")
+			if inPlace {
+				if outputPath != "" {
+					return fmt.Errorf("--in-place and --output cannot be combined")
+				}
+				if args[0] == stdioPath {
+					return fmt.Errorf("--in-place requires a .df file, not stdin")
+				}
+				outputPath = args[0]
+			} else if err := checkOutput(outputPath, args...); err != nil {
+				return fmt.Errorf("%w; use --in-place to rewrite the .df", err)
+			}
+			return runApply(args[0], args[1], env, vars, onlyFrom, mapOnly, codepage, outputPath, lineEnding, backup)
 		},
 	}
 
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	cmd.Flags().BoolVarP(&inPlace, "in-place", "i", false, "Rewrite the .df file itself instead of writing to stdout")
+	addEnvFlag(cmd, &env)
+	addVarFlag(cmd, &vars)
+	cmd.Flags().BoolVar(&mapOnly, "map", false, "Only rename areas through the areaMap of the rules, ignoring all other rules")
+	cmd.Flags().StringArrayVar(&onlyFrom, "only-from", nil, "Only move tables, indexes and LOB fields that are currently in this `area` (repeatable)")
+	addCodepageFlag(cmd, &codepage)
+	addBackupFlag(cmd, &backup)
+	addEOLFlag(cmd, &eol)
 	return cmd
 }
 
-// runApply is the entry point for the apply command.
-func runApply(dfPath, rulesPath, outputPath string) error {
+// runApply is the entry point for the apply command. env selects an
+// environment of the rules, "" for the shared rules, and vars sets variables
+// of area templates. onlyFrom limits the constructs moved to those in these
+// areas, and mapOnly applies the area map alone. eol is the line terminator
+// to write, "" to preserve the input's.
+func runApply(dfPath, rulesPath, env string, vars map[string]string, onlyFrom []string, mapOnly bool, codepage, outputPath, eol, backup string) error {
 	log.Debug().Str("df", dfPath).Str("rules", rulesPath).Str("output", outputPath).Msg("apply started")
 
-	rules, err := loadRules(rulesPath)
+	rules, err := loadRules(rulesPath, env, vars)
 	if err != nil {
-		return fmt.Errorf("loading rules: %w", err)
+		return err
 	}
-	log.Debug().
-		Int("tables", len(rules.SchemaFixer.Tables)).
-		Str("defaultTable", rules.SchemaFixer.Defaults.Table).
-		Str("defaultIndex", rules.SchemaFixer.Defaults.Index).
-		Str("defaultLob", rules.SchemaFixer.Defaults.Lob).
-		Msg("rules loaded")
 
-	lines, err := readLines(dfPath)
+	in, err := openInput(dfPath)
 	if err != nil {
 		return fmt.Errorf("reading df file: %w", err)
 	}
-	log.Debug().Int("lines", len(lines)).Msg("df file read")
-
-	// Use platform-appropriate line endings.
-	lineEnding := "\n"
-	if runtime.GOOS == "windows" {
-		lineEnding = "\r\n"
-	}
-
-	// Detect and strip the trailing checksum line (10 decimal digits).
-	hasChecksum := false
-	processLines := lines
-	if len(lines) > 0 && reChecksum.MatchString(lines[len(lines)-1]) {
-		hasChecksum = true
-		processLines = lines[:len(lines)-1]
-		log.Debug().Msg("trailing checksum detected — will recalculate")
-	}
-
-	// Transform the .df content into a buffer.
-	var buf bytes.Buffer
-	if err := processDF(processLines, &rules.SchemaFixer, &buf, lineEnding); err != nil {
-		return fmt.Errorf("processing df file: %w", err)
-	}
-
-	// Resolve output writer.
-	var out io.Writer = os.Stdout
-	if outputPath != "" {
-		f, err := os.Create(outputPath)
-		if err != nil {
-			return fmt.Errorf("creating output file %q: %w", outputPath, err)
-		}
-		defer f.Close()
-		out = f
-		log.Debug().Str("path", outputPath).Msg("writing to file")
-	}
-
-	if _, err := out.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("writing output: %w", err)
-	}
-
-	if hasChecksum {
-		byteCount := buf.Len()
-		checksumLine := fmt.Sprintf("%010d%s", byteCount, lineEnding)
-		if _, err := fmt.Fprint(out, checksumLine); err != nil {
-			return fmt.Errorf("writing checksum: %w", err)
+	defer in.Close()
+
+	log.Debug().Str("path", outputPath).Msg("writing output")
+
+	// The .df is streamed from input to output one construct at a time,
+	// through a temporary file that only replaces outputPath on success.
+	// Diagnostics that fail the command also keep the output file from
+	// being replaced.
+	diags := newDiagnostics()
+	opts := diags.options()
+	opts.Codepage = codepage
+	opts.EOL = eol
+	opts.OnlyFrom = onlyFrom
+	opts.MapOnly = mapOnly
+	err = replaceFile(outputPath, fileMode(outputPath), backup, func(w io.Writer) error {
+		if _, err := schemafixer.Apply(context.Background(), in, rules, w, opts); err != nil {
+			return err
 		}
-		log.Debug().Int("byteCount", byteCount).Msg("checksum written")
+		return diags.err()
+	}, in)
+	if err != nil {
+		return fmt.Errorf("processing df file: %w", err)
 	}
 
 	log.Debug().Msg("apply complete")
 	return nil
 }
 
-// processDF runs the state-machine line transformer and writes results to buf.
-func processDF(lines []string, rules *SchemaFixerRules, buf *bytes.Buffer, lineEnding string) error {
-	state := stateNone
-	var currentTable, currentField, currentIndex string
-
-	for _, line := range lines {
-		// ── Detect construct type from ADD … lines ────────────────────────
-		if m := reAddTable.FindStringSubmatch(line); m != nil {
-			currentTable = m[1]
-			currentField = ""
-			currentIndex = ""
-			state = stateTable
-			log.Debug().Str("table", currentTable).Msg("parsing TABLE")
-
-		} else if m := reAddField.FindStringSubmatch(line); m != nil {
-			currentField = m[1]
-			currentTable = m[2]
-			currentIndex = ""
-			state = stateField
-			log.Debug().Str("field", currentField).Str("table", currentTable).Msg("parsing FIELD")
-
-		} else if m := reAddIndex.FindStringSubmatch(line); m != nil {
-			currentIndex = m[1]
-			currentTable = m[2]
-			currentField = ""
-			state = stateIndex
-			log.Debug().Str("index", currentIndex).Str("table", currentTable).Msg("parsing INDEX")
-
-		} else if reAddSequence.MatchString(line) {
-			currentTable = ""
-			currentField = ""
-			currentIndex = ""
-			state = stateOther
-
-		} else if strings.TrimSpace(line) == "" {
-			// Blank line marks end of current construct.
-			state = stateNone
-		}
-
-		// ── Area substitution ─────────────────────────────────────────────
-		switch state {
-		case stateTable:
-			if m := reArea.FindStringSubmatch(line); m != nil {
-				area := rules.tableArea(currentTable)
-				line = m[1] + area + m[3]
-				log.Debug().Str("table", currentTable).Str("area", area).Msg("TABLE area replaced")
-			}
-
-		case stateIndex:
-			if m := reArea.FindStringSubmatch(line); m != nil {
-				area := rules.indexArea(currentTable, currentIndex)
-				line = m[1] + area + m[3]
-				log.Debug().Str("index", currentIndex).Str("table", currentTable).Str("area", area).Msg("INDEX area replaced")
-			}
-
-		case stateField:
-			if m := reLobArea.FindStringSubmatch(line); m != nil {
-				area := rules.lobArea(currentTable, currentField)
-				line = m[1] + area + m[3]
-				log.Debug().Str("field", currentField).Str("table", currentTable).Str("area", area).Msg("LOB-AREA replaced")
-			}
-		}
-
-		buf.WriteString(line)
-		buf.WriteString(lineEnding)
-	}
-
-	return nil
-}
-
-// ── Rules lookup helpers ──────────────────────────────────────────────────────
-
-// tableArea returns the area for a table, falling back to the default.
-func (r *SchemaFixerRules) tableArea(tableName string) string {
-	for _, t := range r.Tables {
-		if strings.EqualFold(t.Name, tableName) && t.Area != "" {
-			return t.Area
-		}
-	}
-	return r.Defaults.Table
-}
-
-// indexArea returns the area for a specific index on a table, falling back
-// to the table-level override and then the global default.
-func (r *SchemaFixerRules) indexArea(tableName, indexName string) string {
-	for _, t := range r.Tables {
-		if strings.EqualFold(t.Name, tableName) {
-			for k, v := range t.Indexes {
-				if strings.EqualFold(k, indexName) {
-					return v
-				}
-			}
-		}
-	}
-	return r.Defaults.Index
-}
-
-// lobArea returns the LOB area for a specific field on a table, falling
-// back to the global default.
-func (r *SchemaFixerRules) lobArea(tableName, fieldName string) string {
-	for _, t := range r.Tables {
-		if strings.EqualFold(t.Name, tableName) {
-			for k, v := range t.Lobs {
-				if strings.EqualFold(k, fieldName) {
-					return v
-				}
-			}
-		}
-	}
-	return r.Defaults.Lob
-}
-
-// ── File I/O helpers ──────────────────────────────────────────────────────────
-
-// loadRules reads and unmarshals the YAML rules file.
-func loadRules(path string) (*RulesFile, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	var rules RulesFile
-	if err := yaml.Unmarshal(data, &rules); err != nil {
-		return nil, err
-	}
-	return &rules, nil
-}
-
-// readLines reads a file and returns its lines with line endings stripped.
-// bufio.Scanner automatically handles both LF and CRLF.
-func readLines(path string) ([]string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	return lines, scanner.Err()
+// apiOptions returns the library options shared by all commands: logging
+// through the global zerolog logger. Line endings are preserved unless a
+// command sets EOL from its --eol flag.
+func apiOptions() schemafixer.Options {
+	return schemafixer.Options{Logger: log.Logger}
 }
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bfv/schemafixer"
+	"github.com/spf13/viper"
+)
+
+// diagnostics prints the diagnostics of a command to stderr, one per line
+// as "file:line:col: severity: message", and decides whether they fail the
+// command: errors always do, warnings only with --strict.
+type diagnostics struct {
+	w        io.Writer
+	strict   bool
+	errors   int
+	warnings int
+}
+
+// newDiagnostics returns the diagnostics printer of a command run, honouring
+// the global --strict flag.
+func newDiagnostics() *diagnostics {
+	return &diagnostics{w: os.Stderr, strict: viper.GetBool("strict")}
+}
+
+// report prints d and counts it. It is used as schemafixer.Options.Report.
+func (d *diagnostics) report(diag schemafixer.Diagnostic) {
+	switch diag.Severity {
+	case schemafixer.SeverityError:
+		d.errors++
+	case schemafixer.SeverityWarning:
+		d.warnings++
+	}
+	fmt.Fprintln(d.w, diag)
+}
+
+// options returns apiOptions with diagnostics reported to d.
+func (d *diagnostics) options() schemafixer.Options {
+	opts := apiOptions()
+	opts.Report = d.report
+	return opts
+}
+
+// err returns an error if the diagnostics reported so far fail the command.
+func (d *diagnostics) err() error {
+	switch {
+	case d.errors > 0:
+		return fmt.Errorf("%d error(s) and %d warning(s) found", d.errors, d.warnings)
+	case d.strict && d.warnings > 0:
+		return fmt.Errorf("%d warning(s) found (--strict)", d.warnings)
+	}
+	return nil
+}
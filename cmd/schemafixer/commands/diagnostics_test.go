@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bfv/schemafixer"
+	"github.com/bfv/schemafixer/df"
+)
+
+func TestDiagnostics(t *testing.T) {
+	warning := schemafixer.Diagnostic{Severity: schemafixer.SeverityWarning, File: "a.df", Pos: df.Pos{Line: 3, Col: 5}, Msg: "odd"}
+
+	var buf bytes.Buffer
+	d := &diagnostics{w: &buf}
+	d.report(schemafixer.Diagnostic{Severity: schemafixer.SeverityInfo, File: "a.df", Msg: "note"})
+	d.report(warning)
+	if got, want := buf.String(), "a.df: info: note\na.df:3:5: warning: odd\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if err := d.err(); err != nil {
+		t.Errorf("err() = %v, want nil without --strict", err)
+	}
+
+	d.strict = true
+	if err := d.err(); err == nil {
+		t.Error("err() = nil, want an error for a warning with --strict")
+	}
+
+	d = &diagnostics{w: &buf}
+	d.report(schemafixer.Diagnostic{Severity: schemafixer.SeverityError, Msg: "broken"})
+	if err := d.err(); err == nil {
+		t.Error("err() = nil, want an error for an error")
+	}
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bfv/schemafixer"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// NewExplainCmd builds and returns the 'explain' cobra command.
+func NewExplainCmd() *cobra.Command {
+	var env, codepage string
+	var vars map[string]string
+
+	cmd := &cobra.Command{
+		Use:   "explain <rules.yaml> <schema.df|-> <table|table.index|table.field>",
+		Short: "Show which rule decides the area of a table, index or LOB field, and which rules were skipped",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExplain(cmd.OutOrStdout(), args[0], args[1], args[2], env, vars, codepage)
+		},
+	}
+
+	addEnvFlag(cmd, &env)
+	addVarFlag(cmd, &vars)
+	addCodepageFlag(cmd, &codepage)
+	return cmd
+}
+
+// runExplain is the entry point for the explain command.
+func runExplain(w io.Writer, rulesPath, dfPath, name, env string, vars map[string]string, codepage string) error {
+	log.Debug().Str("rules", rulesPath).Str("df", dfPath).Str("name", name).Msg("explain started")
+
+	rules, err := loadRules(rulesPath, env, vars)
+	if err != nil {
+		return err
+	}
+
+	in, err := openInput(dfPath)
+	if err != nil {
+		return fmt.Errorf("reading df file: %w", err)
+	}
+	defer in.Close()
+
+	diags := newDiagnostics()
+	opts := diags.options()
+	opts.Codepage = codepage
+	res, err := schemafixer.Explain(context.Background(), in, rules, name, opts)
+	if err != nil {
+		return err
+	}
+	for i, e := range res.Explanations {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		writeExplanation(w, e)
+	}
+	return diags.err()
+}
+
+// writeExplanation prints e: the construct and its area, then the rules
+// considered, one per line, with their location and why they were skipped.
+func writeExplanation(w io.Writer, e schemafixer.Explanation) {
+	fmt.Fprintf(w, "%s %s (%s): %q", strings.ToLower(e.Kind.String()), e.Name, location(e.File, e.Line), e.Area)
+	if !strings.EqualFold(e.Area, e.Current) {
+		fmt.Fprintf(w, ", currently %q", e.Current)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range e.Steps {
+		status := "decided"
+		switch {
+		case s.Skipped != "":
+			status = "skipped"
+		case strings.HasPrefix(s.Rule, "areaMap."):
+			status = "mapped"
+		}
+		area := "-"
+		if s.Area != "" {
+			area = fmt.Sprintf("%q", s.Area)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s", status, s.Rule, area, location(s.File, s.Line))
+		if s.Skipped != "" {
+			fmt.Fprintf(tw, "\t%s", s.Skipped)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// location returns file:line, or what is known of it.
+func location(file string, line int) string {
+	switch {
+	case line == 0:
+		return file
+	case file == "":
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
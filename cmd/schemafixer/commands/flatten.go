@@ -1,53 +1,50 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
-	"runtime"
 	"strings"
 
+	"github.com/bfv/schemafixer"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
-// Regular expressions for the flatten command.
-var (
-	reFlattenArea    = regexp.MustCompile(`(?m)^  AREA ".*"$`)
-	reFlattenLobArea = regexp.MustCompile(`(?m)^  LOB-AREA ".*"$`)
-	reFlattenCan     = regexp.MustCompile(`(?m)^  CAN-.*$\n?`)
-)
-
-const (
-	flattenAreaReplacement    = `  AREA "Schema Area"`
-	flattenLobAreaReplacement = `  LOB-AREA "Schema Area"`
-)
-
 // NewFlattenCmd builds and returns the 'flatten' cobra command.
 func NewFlattenCmd() *cobra.Command {
-	var outputPath string
+	var outputPath, eol, backup string
 
 	cmd := &cobra.Command{
-		Use:   "flatten <directory|file.df> [file2.df ...]",
+		Use:   "flatten <directory|file.df|-> [file2.df ...]",
 		Short: `Reset all AREA/LOB-AREA values to "Schema Area" and strip CAN- lines`,
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runFlatten(args, outputPath)
+			lineEnding, err := parseEOL(eol)
+			if err != nil {
+				return err
+			}
+			return runFlatten(args, outputPath, lineEnding, backup)
 		},
 	}
 
-	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "Write result to this file/directory instead of overwriting in place (single input: file path; directory input: output directory)")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "Write result to this file/directory instead of overwriting in place (single input: file path; directory input: output directory; stdin input: defaults to stdout)")
+	addBackupFlag(cmd, &backup)
+	addEOLFlag(cmd, &eol)
 	return cmd
 }
 
 // runFlatten resolves the input arguments to a concrete file list and processes each one.
-func runFlatten(args []string, outputPath string) error {
+// eol is the line terminator to write, "" to preserve the input's; backup is
+// the --backup suffix.
+func runFlatten(args []string, outputPath, eol, backup string) error {
 	var files []string
 	dirMode := false
 
-	if len(args) == 1 {
+	if len(args) == 1 && args[0] != stdioPath {
 		info, err := os.Stat(args[0])
 		if err != nil {
 			return fmt.Errorf("stat %q: %w", args[0], err)
@@ -91,7 +88,12 @@ func runFlatten(args []string, outputPath string) error {
 			dest = outputPath
 		}
 
-		if err := flattenFile(path, dest); err != nil {
+		if dest != path {
+			if err := checkOutput(dest, path); err != nil {
+				return fmt.Errorf("%w; omit --output to flatten in place", err)
+			}
+		}
+		if err := flattenFile(path, dest, eol, backup); err != nil {
 			return fmt.Errorf("flattening %q: %w", path, err)
 		}
 	}
@@ -101,50 +103,38 @@ func runFlatten(args []string, outputPath string) error {
 
 // flattenFile applies the flatten transformations to srcPath and writes the
 // result to destPath.
-//
-// The .df trailer declares its own codepage via a "cpstream=<name>" line
-// (see the end of any .df file), so no encoding assumption is made here.
-// The file is treated as a raw byte sequence — exactly like readLines/
-// processDF in apply.go — since AREA/LOB-AREA/CAN- constructs are always
-// plain ASCII; any multi-byte payload elsewhere in the file (descriptions,
-// labels, etc.) is passed through untouched regardless of its codepage.
-func flattenFile(srcPath, destPath string) error {
+func flattenFile(srcPath, destPath, eol, backup string) error {
 	log.Debug().Str("file", srcPath).Msg("processing")
 
-	raw, err := os.ReadFile(srcPath)
+	in, err := openInput(srcPath)
 	if err != nil {
 		return err
 	}
-
-	// Normalize to LF for regex processing, then restore platform line
-	// endings on write.
-	content := strings.ReplaceAll(string(raw), "\r\n", "\n")
-
-	areaCount := len(reFlattenArea.FindAllString(content, -1))
-	lobAreaCount := len(reFlattenLobArea.FindAllString(content, -1))
-	canCount := len(reFlattenCan.FindAllString(content, -1))
-
-	newContent := reFlattenArea.ReplaceAllString(content, flattenAreaReplacement)
-	newContent = reFlattenLobArea.ReplaceAllString(newContent, flattenLobAreaReplacement)
-	newContent = reFlattenCan.ReplaceAllString(newContent, "")
-
-	lineEnding := "\n"
-	if runtime.GOOS == "windows" {
-		lineEnding = "\r\n"
-	}
-	if lineEnding != "\n" {
-		newContent = strings.ReplaceAll(newContent, "\n", lineEnding)
-	}
-
-	if err := os.WriteFile(destPath, []byte(newContent), fileMode(srcPath)); err != nil {
+	defer in.Close()
+
+	// The result is streamed into a temporary file that replaces destPath,
+	// which may be srcPath itself. Diagnostics that fail the command keep
+	// it from being replaced.
+	diags := newDiagnostics()
+	opts := diags.options()
+	opts.EOL = eol
+	var res *schemafixer.FlattenResult
+	err = replaceFile(destPath, fileMode(srcPath), backup, func(w io.Writer) error {
+		var err error
+		if res, err = schemafixer.Flatten(context.Background(), in, w, opts); err != nil {
+			return err
+		}
+		return diags.err()
+	}, in)
+	if err != nil {
 		return err
 	}
 
 	log.Info().
 		Str("file", filepath.Base(srcPath)).
-		Int("area", areaCount).
-		Int("lobArea", lobAreaCount).
-		Int("canDeleted", canCount).
+		Int("area", res.Areas).
+		Int("lobArea", res.LobAreas).
+		Int("canDeleted", res.CanDeleted).
 		Msg("flattened")
 
 	return nil
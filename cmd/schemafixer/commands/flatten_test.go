@@ -3,7 +3,6 @@ package commands
 import (
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"testing"
 )
@@ -12,6 +11,7 @@ func TestFlattenFile(t *testing.T) {
 	tests := []struct {
 		name    string
 		input   string
+		eol     string
 		want    string
 		wantErr bool
 	}{
@@ -34,10 +34,22 @@ func TestFlattenFile(t *testing.T) {
 				"\n",
 		},
 		{
-			name:  "CRLF line endings are normalized and restored",
+			name:  "CRLF line endings are preserved",
 			input: "ADD TABLE \"Item\"\r\n  AREA \"Data Area\"\r\n\r\n",
+			want:  "ADD TABLE \"Item\"\r\n  AREA \"Schema Area\"\r\n\r\n",
+		},
+		{
+			name:  "CRLF line endings converted to LF",
+			input: "ADD TABLE \"Item\"\r\n  AREA \"Data Area\"\r\n\r\n",
+			eol:   "\n",
 			want:  "ADD TABLE \"Item\"\n  AREA \"Schema Area\"\n\n",
 		},
+		{
+			name:  "missing final newline stays missing",
+			input: "ADD FIELD \"Name\" OF \"Item\" AS character \n  LOB-AREA \"Lob Area\"",
+			eol:   "\r\n",
+			want:  "ADD FIELD \"Name\" OF \"Item\" AS character \r\n  LOB-AREA \"Schema Area\"",
+		},
 		{
 			name:  "content without AREA/LOB-AREA/CAN- lines is untouched",
 			input: "ADD SEQUENCE \"NextItemNum\"\n  INITIAL 1000\n\n",
@@ -60,7 +72,7 @@ func TestFlattenFile(t *testing.T) {
 				t.Fatalf("writing fixture: %v", err)
 			}
 
-			err := flattenFile(src, dst)
+			err := flattenFile(src, dst, tt.eol, "")
 			if tt.wantErr {
 				if err == nil {
 					t.Fatalf("expected error, got nil")
@@ -76,13 +88,8 @@ func TestFlattenFile(t *testing.T) {
 				t.Fatalf("reading output: %v", err)
 			}
 
-			want := tt.want
-			if runtime.GOOS == "windows" {
-				want = strings.ReplaceAll(want, "\n", "\r\n")
-			}
-
-			if string(got) != want {
-				t.Errorf("flattenFile() output mismatch\ngot:  %q\nwant: %q", got, want)
+			if string(got) != tt.want {
+				t.Errorf("flattenFile() output mismatch\ngot:  %q\nwant: %q", got, tt.want)
 			}
 		})
 	}
@@ -90,12 +97,35 @@ func TestFlattenFile(t *testing.T) {
 
 func TestFlattenFile_MissingSource(t *testing.T) {
 	dir := t.TempDir()
-	err := flattenFile(filepath.Join(dir, "does-not-exist.df"), filepath.Join(dir, "out.df"))
+	err := flattenFile(filepath.Join(dir, "does-not-exist.df"), filepath.Join(dir, "out.df"), "", "")
 	if err == nil {
 		t.Fatal("expected error for missing source file, got nil")
 	}
 }
 
+func TestFlattenFile_Truncated(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "in.df")
+	dst := filepath.Join(dir, "out.df")
+	if err := os.WriteFile(src, []byte("ADD TABLE \"Item\"\n  AREA \"Data Area\"\n  DESCRIPTION \"cut off"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	if err := os.WriteFile(dst, []byte("previous\n"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	if err := flattenFile(src, dst, "", ""); err == nil {
+		t.Fatal("expected error for a truncated file, got nil")
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if string(got) != "previous\n" {
+		t.Errorf("output was replaced: %q", got)
+	}
+}
+
 func TestRunFlatten_SingleFileInPlace(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "in.df")
@@ -103,7 +133,7 @@ func TestRunFlatten_SingleFileInPlace(t *testing.T) {
 		t.Fatalf("writing fixture: %v", err)
 	}
 
-	if err := runFlatten([]string{path}, ""); err != nil {
+	if err := runFlatten([]string{path}, "", "", ""); err != nil {
 		t.Fatalf("runFlatten() error = %v", err)
 	}
 
@@ -124,7 +154,7 @@ func TestRunFlatten_SingleFileWithOutput(t *testing.T) {
 		t.Fatalf("writing fixture: %v", err)
 	}
 
-	if err := runFlatten([]string{src}, out); err != nil {
+	if err := runFlatten([]string{src}, out, "", ""); err != nil {
 		t.Fatalf("runFlatten() error = %v", err)
 	}
 
@@ -161,7 +191,7 @@ func TestRunFlatten_DirectoryMode(t *testing.T) {
 		}
 	}
 
-	if err := runFlatten([]string{inDir}, outDir); err != nil {
+	if err := runFlatten([]string{inDir}, outDir, "", ""); err != nil {
 		t.Fatalf("runFlatten() error = %v", err)
 	}
 
@@ -190,7 +220,7 @@ func TestRunFlatten_MultipleFilesWithOutputIsError(t *testing.T) {
 		}
 	}
 
-	err := runFlatten([]string{a, b}, filepath.Join(dir, "out.df"))
+	err := runFlatten([]string{a, b}, filepath.Join(dir, "out.df"), "", "")
 	if err == nil {
 		t.Fatal("expected error when using --output with multiple file arguments, got nil")
 	}
@@ -207,7 +237,7 @@ func TestRunFlatten_MultipleFilesInPlace(t *testing.T) {
 		t.Fatalf("writing fixture b: %v", err)
 	}
 
-	if err := runFlatten([]string{a, b}, ""); err != nil {
+	if err := runFlatten([]string{a, b}, "", "", ""); err != nil {
 		t.Fatalf("runFlatten() error = %v", err)
 	}
 
@@ -223,8 +253,38 @@ func TestRunFlatten_MultipleFilesInPlace(t *testing.T) {
 }
 
 func TestRunFlatten_MissingDirectory(t *testing.T) {
-	err := runFlatten([]string{filepath.Join(t.TempDir(), "does-not-exist")}, "")
+	err := runFlatten([]string{filepath.Join(t.TempDir(), "does-not-exist")}, "", "", "")
 	if err == nil {
 		t.Fatal("expected error for missing input path, got nil")
 	}
 }
+
+func TestRunFlatten_StdinToOutputFile(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating pipe: %v", err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	// A line beyond bufio.Scanner's default 64 KiB limit must pass through.
+	long := strings.Repeat("x", 100*1024)
+	go func() {
+		w.WriteString("ADD TABLE \"Item\"\n  AREA \"Data Area\"\n  DESCRIPTION \"" + long + "\"\n")
+		w.Close()
+	}()
+
+	out := filepath.Join(t.TempDir(), "out.df")
+	if err := runFlatten([]string{"-"}, out, "", ""); err != nil {
+		t.Fatalf("runFlatten() error = %v", err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if !strings.Contains(string(got), `AREA "Schema Area"`) || !strings.Contains(string(got), long) {
+		t.Errorf("unexpected output (%d bytes)", len(got))
+	}
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// stdioPath is the path argument that selects stdin (for inputs) or stdout
+// (for outputs).
+const stdioPath = "-"
+
+// eolValues maps the values of the --eol flag to line terminators; ""
+// keeps each line's terminator as read.
+var eolValues = map[string]string{
+	"preserve": "",
+	"lf":       "\n",
+	"crlf":     "\r\n",
+}
+
+// addEOLFlag registers the --eol flag of the commands that write a .df.
+func addEOLFlag(cmd *cobra.Command, eol *string) {
+	cmd.Flags().StringVar(eol, "eol", "preserve", "Line endings of the output: preserve, lf or crlf")
+}
+
+// parseEOL returns the line terminator for an --eol flag value.
+func parseEOL(value string) (string, error) {
+	eol, ok := eolValues[value]
+	if !ok {
+		return "", fmt.Errorf("invalid --eol %q: must be preserve, lf or crlf", value)
+	}
+	return eol, nil
+}
+
+// stdin is the input for "-". It names itself in diagnostics and hides
+// that os.Stdin implements io.Seeker, which fails on pipes.
+type stdin struct{ io.Reader }
+
+func (stdin) Name() string { return "<stdin>" }
+func (stdin) Close() error { return nil }
+
+// openInput opens path for reading, or returns stdin for "-".
+func openInput(path string) (io.ReadCloser, error) {
+	if path == stdioPath {
+		return stdin{os.Stdin}, nil
+	}
+	return os.Open(path)
+}
+
+// addCodepageFlag registers the --codepage flag of the commands that read a
+// .df.
+func addCodepageFlag(cmd *cobra.Command, codepage *string) {
+	cmd.Flags().StringVar(codepage, "codepage", "", "Codepage of the .df input, e.g. ISO8859-1 (default: cpstream from the trailer, UTF-8 for stdin)")
+}
+
+// addBackupFlag registers the --backup flag of the commands that write files.
+func addBackupFlag(cmd *cobra.Command, backup *string) {
+	cmd.Flags().StringVar(backup, "backup", "", "Keep the previous version of an overwritten output file under its name plus this suffix, e.g. .bak")
+}
+
+// checkOutput refuses an output path that resolves to the same file as one
+// of the inputs, which would otherwise be overwritten. Stdout and outputs
+// that do not exist yet are always fine.
+func checkOutput(outputPath string, inputs ...string) error {
+	if outputPath == "" || outputPath == stdioPath {
+		return nil
+	}
+	out, err := os.Stat(outputPath)
+	if err != nil {
+		return nil
+	}
+	for _, input := range inputs {
+		if input == stdioPath {
+			continue
+		}
+		if in, err := os.Stat(input); err == nil && os.SameFile(in, out) {
+			return fmt.Errorf("output %q is the same file as input %q", outputPath, input)
+		}
+	}
+	return nil
+}
+
+// replaceFile streams the output of write into a temporary file next to
+// path and renames it over path once write succeeded, so path is never left
+// truncated or half-written and may also be the file being read. The inputs
+// are closed once write returns, before path is replaced, since Windows
+// cannot rename over a file that is open. With a non-empty backup suffix an
+// existing path is first preserved as path+backup. "" and "-" write to
+// stdout instead, all at once after write succeeded.
+func replaceFile(path string, perm fs.FileMode, backup string, write func(io.Writer) error, inputs ...io.Closer) error {
+	if path == "" || path == stdioPath {
+		var buf bytes.Buffer
+		err := write(&buf)
+		closeAll(inputs)
+		if err != nil {
+			return err
+		}
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		closeAll(inputs)
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	w := bufio.NewWriter(tmp)
+	err = write(w)
+	closeAll(inputs)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && backup != "" {
+		err = backupFile(path, path+backup)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// closeAll closes inputs, which were only read.
+func closeAll(inputs []io.Closer) {
+	for _, c := range inputs {
+		c.Close()
+	}
+}
+
+// backupFile keeps a copy of path at dest, replacing an older backup. A
+// missing path needs no backup. The copy is a hard link where possible.
+func backupFile(path, dest string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing old backup: %w", err)
+	}
+	if err := os.Link(path, dest); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode())
+	if err != nil {
+		return fmt.Errorf("creating backup: %w", err)
+	}
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
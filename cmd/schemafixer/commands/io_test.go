@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceFile_Backup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.df")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	err := replaceFile(path, 0o644, ".bak", func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("replaceFile() error = %v", err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != "new" {
+		t.Errorf("output = %q, want %q", got, "new")
+	}
+	if got, _ := os.ReadFile(path + ".bak"); string(got) != "old" {
+		t.Errorf("backup = %q, want %q", got, "old")
+	}
+}
+
+func TestReplaceFile_FailureKeepsTarget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.df")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	boom := errors.New("boom")
+	err := replaceFile(path, 0o644, ".bak", func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("replaceFile() error = %v, want %v", err, boom)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != "old" {
+		t.Errorf("target changed to %q after a failed write", got)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the target in %s, found %d entries", dir, len(entries))
+	}
+}
+
+func TestReplaceFile_ClosesInputBeforeRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "in.df")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	// The source stays open while it is read and replaced, as in an
+	// in-place flatten; Windows cannot rename over it until it is closed.
+	err = replaceFile(path, 0o644, "", func(w io.Writer) error {
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, " new"...))
+		return err
+	}, in)
+	if err != nil {
+		t.Fatalf("replaceFile() error = %v", err)
+	}
+	if _, err := in.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("input not closed: Read() error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "old new" {
+		t.Errorf("output = %q, want %q", got, "old new")
+	}
+}
+
+func TestReplaceFile_StdoutOnlyOnSuccess(t *testing.T) {
+	stdout := os.Stdout
+	defer func() { os.Stdout = stdout }()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdout = f
+
+	// More than a bufio buffer is written before the failure.
+	boom := errors.New("boom")
+	err = replaceFile("-", 0o644, "", func(w io.Writer) error {
+		io.WriteString(w, strings.Repeat("x", 64<<10))
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("replaceFile() error = %v, want %v", err, boom)
+	}
+	if info, _ := f.Stat(); info.Size() != 0 {
+		t.Errorf("%d bytes written to stdout after a failed write", info.Size())
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.df")
+	if err := os.WriteFile(in, []byte("x"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	link := filepath.Join(dir, "link.df")
+	if err := os.Symlink(in, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if err := checkOutput(link, "-", in); err == nil || !strings.Contains(err.Error(), "same file") {
+		t.Errorf("checkOutput() error = %v, want same file error", err)
+	}
+	for _, out := range []string{"", "-", filepath.Join(dir, "new.df")} {
+		if err := checkOutput(out, in); err != nil {
+			t.Errorf("checkOutput(%q) error = %v", out, err)
+		}
+	}
+}
+
+func TestRunApply_InPlace(t *testing.T) {
+	dir := t.TempDir()
+	dfPath := filepath.Join(dir, "schema.df")
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(dfPath, []byte("ADD TABLE \"Item\"\r\n  AREA \"Schema Area\"\r\n\r\n"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	if err := os.WriteFile(rulesPath, []byte("schemafixer:\n  version: 1.0\n  defaults:\n    table: Data\n"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	if err := runApply(dfPath, rulesPath, "", nil, nil, false, "", dfPath, "", ".orig"); err != nil {
+		t.Fatalf("runApply() error = %v", err)
+	}
+
+	if got, _ := os.ReadFile(dfPath); string(got) != "ADD TABLE \"Item\"\r\n  AREA \"Data\"\r\n\r\n" {
+		t.Errorf("in-place output = %q", got)
+	}
+	if got, _ := os.ReadFile(dfPath + ".orig"); !strings.Contains(string(got), "Schema Area") {
+		t.Errorf("backup = %q, want the original", got)
+	}
+}
+
+func TestRunApply_StdinCodepage(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rulesPath, []byte("schemafixer:\n  tables:\n    - name: größe\n      area: Daten\n"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating pipe: %v", err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	// A table named "Größe" in ISO8859-1; stdin has no trailer to detect it from.
+	src := "ADD TABLE \"GR\xd6\xdfE\"\n  AREA \"Schema Area\"\n\n"
+	go func() {
+		w.WriteString(src)
+		w.Close()
+	}()
+
+	out := filepath.Join(dir, "out.df")
+	if err := runApply(stdioPath, rulesPath, "", nil, nil, false, "ISO8859-1", out, "", ""); err != nil {
+		t.Fatalf("runApply() error = %v", err)
+	}
+
+	want := "ADD TABLE \"GR\xd6\xdfE\"\n  AREA \"Daten\"\n\n"
+	if got, _ := os.ReadFile(out); string(got) != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
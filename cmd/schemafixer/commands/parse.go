@@ -1,12 +1,11 @@
 package commands
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
-	"os"
-	"strings"
 
+	"github.com/bfv/schemafixer"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -14,178 +13,75 @@ import (
 
 // NewParseCmd builds and returns the 'parse' cobra command.
 func NewParseCmd() *cobra.Command {
-	var outputFile string
+	var outputFile, backup, env, codepage string
+	var groups bool
+	var vars map[string]string
 
 	cmd := &cobra.Command{
-		Use:   "parse <schema.df> <rules.yaml>",
+		Use:   "parse <schema.df|-> <rules.yaml>",
 		Short: "Generate a rules file from an existing .df schema",
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runParse(args[0], args[1], outputFile)
+			if err := checkOutput(outputFile, args...); err != nil {
+				return err
+			}
+			return runParse(args[0], args[1], env, vars, codepage, outputFile, backup, groups)
 		},
 	}
 
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	cmd.Flags().BoolVar(&groups, "groups", false, "Emit groups for tables that share the same areas")
+	addEnvFlag(cmd, &env)
+	addVarFlag(cmd, &vars)
+	addCodepageFlag(cmd, &codepage)
+	addBackupFlag(cmd, &backup)
 	return cmd
 }
 
-// runParse is the entry point for the parse command.
-func runParse(dfPath, rulesPath, outputPath string) error {
+// runParse is the entry point for the parse command. env selects the
+// environment whose defaults are left out of the output, and vars sets
+// variables of their area templates; codepage is that of the .df, "" to
+// detect it; groups replaces tables with identical areas by groups.
+func runParse(dfPath, rulesPath, env string, vars map[string]string, codepage, outputPath, backup string, groups bool) error {
 	log.Debug().Str("df", dfPath).Str("rules", rulesPath).Str("output", outputPath).Msg("parse started")
 
-	rules, err := loadRules(rulesPath)
+	rules, err := loadRules(rulesPath, env, vars)
 	if err != nil {
-		return fmt.Errorf("loading rules: %w", err)
+		return err
 	}
-	defaults := rules.SchemaFixer.Defaults
-	log.Debug().
-		Str("defaultTable", defaults.Table).
-		Str("defaultIndex", defaults.Index).
-		Str("defaultLob", defaults.Lob).
-		Msg("defaults loaded")
 
-	lines, err := readLines(dfPath)
+	in, err := openInput(dfPath)
 	if err != nil {
 		return fmt.Errorf("reading df file: %w", err)
 	}
-	log.Debug().Int("lines", len(lines)).Msg("df file read")
-
-	// tableRules accumulates per-table rules keyed by lowercased table name.
-	// We also keep insertion order via a separate slice.
-	type tableEntry struct {
-		name    string // original casing from .df
-		area    string // non-default area, empty = use default
-		indexes map[string]string
-		lobs    map[string]string
-	}
-	tableOrder := []string{} // lower-cased names, insertion order
-	tableMap := map[string]*tableEntry{}
-
-	getOrCreate := func(tableName string) *tableEntry {
-		key := strings.ToLower(tableName)
-		if _, ok := tableMap[key]; !ok {
-			tableMap[key] = &tableEntry{
-				name:    tableName,
-				indexes: map[string]string{},
-				lobs:    map[string]string{},
-			}
-			tableOrder = append(tableOrder, key)
-		}
-		return tableMap[key]
-	}
-
-	// State machine — same approach as apply.
-	state := stateNone
-	var currentTable, currentField, currentIndex string
-
-	for _, line := range lines {
-		if m := reAddTable.FindStringSubmatch(line); m != nil {
-			currentTable = m[1]
-			currentField = ""
-			currentIndex = ""
-			state = stateTable
-			log.Debug().Str("table", currentTable).Msg("parsing TABLE")
-
-		} else if m := reAddField.FindStringSubmatch(line); m != nil {
-			currentField = m[1]
-			currentTable = m[2]
-			currentIndex = ""
-			state = stateField
-			log.Debug().Str("field", currentField).Str("table", currentTable).Msg("parsing FIELD")
-
-		} else if m := reAddIndex.FindStringSubmatch(line); m != nil {
-			currentIndex = m[1]
-			currentTable = m[2]
-			currentField = ""
-			state = stateIndex
-			log.Debug().Str("index", currentIndex).Str("table", currentTable).Msg("parsing INDEX")
-
-		} else if reAddSequence.MatchString(line) {
-			currentTable = ""
-			currentField = ""
-			currentIndex = ""
-			state = stateOther
-
-		} else if strings.TrimSpace(line) == "" {
-			state = stateNone
-		}
+	defer in.Close()
 
-		switch state {
-		case stateTable:
-			if m := reArea.FindStringSubmatch(line); m != nil {
-				area := m[2]
-				if !strings.EqualFold(area, defaults.Table) {
-					e := getOrCreate(currentTable)
-					e.area = area
-					log.Debug().Str("table", currentTable).Str("area", area).Msg("non-default TABLE area")
-				}
-			}
-
-		case stateIndex:
-			if m := reArea.FindStringSubmatch(line); m != nil {
-				area := m[2]
-				if !strings.EqualFold(area, defaults.Index) {
-					e := getOrCreate(currentTable)
-					e.indexes[strings.ToLower(currentIndex)] = area
-					log.Debug().Str("index", currentIndex).Str("table", currentTable).Str("area", area).Msg("non-default INDEX area")
-				}
-			}
-
-		case stateField:
-			if m := reLobArea.FindStringSubmatch(line); m != nil {
-				area := m[2]
-				if !strings.EqualFold(area, defaults.Lob) {
-					e := getOrCreate(currentTable)
-					e.lobs[currentField] = area
-					log.Debug().Str("field", currentField).Str("table", currentTable).Str("area", area).Msg("non-default LOB area")
-				}
-			}
-		}
+	diags := newDiagnostics()
+	opts := diags.options()
+	opts.Codepage = codepage
+	out, err := schemafixer.ParseRules(context.Background(), in, rules, opts)
+	if err != nil {
+		return fmt.Errorf("parsing df file: %w", err)
 	}
-
-	// Build the output RulesFile — defaults come first, then per-table rules.
-	out := RulesFile{
-		SchemaFixer: SchemaFixerRules{
-			Version:  rules.SchemaFixer.Version,
-			Defaults: defaults,
-		},
+	if err := diags.err(); err != nil {
+		return err
 	}
 
-	for _, key := range tableOrder {
-		e := tableMap[key]
-		tr := TableRule{
-			Name: e.name,
-			Area: e.area,
-		}
-		if len(e.indexes) > 0 {
-			tr.Indexes = e.indexes
-		}
-		if len(e.lobs) > 0 {
-			tr.Lobs = e.lobs
-		}
-		out.SchemaFixer.Tables = append(out.SchemaFixer.Tables, tr)
+	if groups {
+		out.SchemaFixer.GroupTables()
 	}
 
 	// Marshal to YAML.
-	data, err := yaml.Marshal(&out)
+	data, err := yaml.Marshal(out)
 	if err != nil {
 		return fmt.Errorf("marshalling yaml: %w", err)
 	}
 
-	// Resolve output writer.
-	var w io.Writer = os.Stdout
-	if outputPath != "" {
-		f, err := os.Create(outputPath)
-		if err != nil {
-			return fmt.Errorf("creating output file %q: %w", outputPath, err)
-		}
-		defer f.Close()
-		w = bufio.NewWriter(f)
-		log.Debug().Str("path", outputPath).Msg("writing to file")
-		defer w.(*bufio.Writer).Flush()
-	}
-
-	if _, err := w.Write(data); err != nil {
+	err = replaceFile(outputPath, fileMode(outputPath), backup, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("writing output: %w", err)
 	}
 
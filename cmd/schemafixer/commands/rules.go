@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bfv/schemafixer"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// NewRulesCmd builds and returns the 'rules' cobra command, which groups
+// the commands that work on rules files.
+func NewRulesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Inspect rules files",
+	}
+	cmd.AddCommand(newRulesResolveCmd())
+	cmd.AddCommand(newRulesSchemaCmd())
+	cmd.AddCommand(newRulesMigrateCmd())
+	return cmd
+}
+
+// newRulesResolveCmd builds the 'rules resolve' command.
+func newRulesResolveCmd() *cobra.Command {
+	var outputFile, backup, env string
+
+	cmd := &cobra.Command{
+		Use:   "resolve <rules.yaml>",
+		Short: "Print the composed rules as apply uses them, optionally for one environment",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkOutput(outputFile, args...); err != nil {
+				return err
+			}
+			return runRulesResolve(args[0], env, outputFile, backup)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	addEnvFlag(cmd, &env)
+	addBackupFlag(cmd, &backup)
+	return cmd
+}
+
+// runRulesResolve is the entry point for the rules resolve command.
+func runRulesResolve(rulesPath, env, outputPath, backup string) error {
+	rules, err := loadRules(rulesPath, env, nil)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(schemafixer.RulesFile{SchemaFixer: *rules})
+	if err != nil {
+		return fmt.Errorf("marshalling yaml: %w", err)
+	}
+	err = replaceFile(outputPath, fileMode(outputPath), backup, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
+
+// newRulesSchemaCmd builds the 'rules schema' command.
+func newRulesSchemaCmd() *cobra.Command {
+	var outputFile, backup string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of rules files, for editors to validate and complete them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRulesSchema(outputFile, backup)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	addBackupFlag(cmd, &backup)
+	return cmd
+}
+
+// runRulesSchema is the entry point for the rules schema command.
+func runRulesSchema(outputPath, backup string) error {
+	data, err := schemafixer.RulesSchema()
+	if err != nil {
+		return fmt.Errorf("generating schema: %w", err)
+	}
+	err = replaceFile(outputPath, fileMode(outputPath), backup, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
+
+// newRulesMigrateCmd builds the 'rules migrate' command.
+func newRulesMigrateCmd() *cobra.Command {
+	var outputFile, backup string
+	var inPlace bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate <rules.yaml>",
+		Short: "Rewrite a rules file in the current format, keeping its comments",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			outputPath := outputFile
+			if inPlace {
+				if outputPath != "" {
+					return fmt.Errorf("--in-place and --output cannot be combined")
+				}
+				outputPath = args[0]
+			} else if err := checkOutput(outputPath, args...); err != nil {
+				return fmt.Errorf("%w; use --in-place to rewrite the rules file", err)
+			}
+			return runRulesMigrate(args[0], outputPath, backup)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	cmd.Flags().BoolVarP(&inPlace, "in-place", "i", false, "Rewrite the rules file itself instead of writing to stdout")
+	addBackupFlag(cmd, &backup)
+	return cmd
+}
+
+// runRulesMigrate is the entry point for the rules migrate command.
+func runRulesMigrate(rulesPath, outputPath, backup string) error {
+	in, err := os.Open(rulesPath)
+	if err != nil {
+		return fmt.Errorf("reading rules: %w", err)
+	}
+	defer in.Close()
+
+	var res *schemafixer.MigrateResult
+	err = replaceFile(outputPath, fileMode(outputPath), backup, func(w io.Writer) error {
+		var err error
+		res, err = schemafixer.MigrateRules(in, w)
+		return err
+	}, in)
+	if err != nil {
+		return fmt.Errorf("migrating rules: %w", err)
+	}
+
+	if len(res.Steps) == 0 {
+		log.Info().Str("rules", rulesPath).Float64("version", res.To).Msg("already current")
+		return nil
+	}
+	for _, step := range res.Steps {
+		log.Info().Str("rules", rulesPath).Msg(step)
+	}
+	return nil
+}
+
+// addEnvFlag registers the --env flag that selects an environment of the
+// rules file.
+func addEnvFlag(cmd *cobra.Command, env *string) {
+	cmd.Flags().StringVar(env, "env", "", "Merge the named environment of the rules file over the shared rules")
+}
+
+// addVarFlag registers the --var flag that sets variables of area
+// templates.
+func addVarFlag(cmd *cobra.Command, vars *map[string]string) {
+	cmd.Flags().StringToStringVar(vars, "var", nil, "Set a variable of area templates as `name=value`; repeatable, overrides the environment variable")
+}
+
+// loadRules loads the rules file at path, selects env, "" for the shared
+// rules, and sets the template variables vars.
+func loadRules(path, env string, vars map[string]string) (*schemafixer.SchemaFixerRules, error) {
+	rf, err := schemafixer.LoadRules(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading rules: %w", err)
+	}
+	rules, err := rf.SchemaFixer.Environment(env)
+	if err != nil {
+		return nil, fmt.Errorf("loading rules: %w", err)
+	}
+	rules.Vars = vars
+	log.Debug().Str("rules", path).Str("env", env).Msg("rules loaded")
+	return rules, nil
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunRulesMigrate_InPlace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	src := "# Production areas\n" +
+		"schemafixer:\n" +
+		"  version: 1.0\n" +
+		"  defaults:\n" +
+		"    table: DataArea # most tables\n" +
+		"    index: Idx{{A}}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	if err := runRulesMigrate(path, path, ".bak"); err != nil {
+		t.Fatalf("runRulesMigrate() error = %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading result: %v", err)
+	}
+	want := strings.NewReplacer("version: 1.0", "version: 1.1", "Idx{{A}}", `'Idx{{"{"}}{{"{"}}A}}'`).Replace(src)
+	if string(got) != want {
+		t.Errorf("migrated rules:\n%s\nwant:\n%s", got, want)
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); string(backup) != src {
+		t.Errorf("backup = %q, want the original", backup)
+	}
+
+	// Migrating again leaves the file as it is.
+	if err := runRulesMigrate(path, path, ""); err != nil {
+		t.Fatalf("runRulesMigrate() error = %v", err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != want {
+		t.Errorf("second migration changed the rules:\n%s", again)
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/bfv/schemafixer"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// NewTranscodeCmd builds and returns the 'transcode' cobra command.
+func NewTranscodeCmd() *cobra.Command {
+	var outputFile, from, to, eol, backup string
+
+	cmd := &cobra.Command{
+		Use:   "transcode <file.df|-> --to <codepage>",
+		Short: "Convert a .df schema file to another codepage and update its trailer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lineEnding, err := parseEOL(eol)
+			if err != nil {
+				return err
+			}
+			if err := checkOutput(outputFile, args[0]); err != nil {
+				return err
+			}
+			return runTranscode(args[0], from, to, outputFile, lineEnding, backup)
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "Codepage to convert to, e.g. UTF-8 or ISO8859-1")
+	cmd.Flags().StringVar(&from, "from", "", "Codepage of the input (default: cpstream from the trailer, UTF-8 for stdin)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write output to file instead of stdout")
+	addBackupFlag(cmd, &backup)
+	addEOLFlag(cmd, &eol)
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+// runTranscode is the entry point for the transcode command. eol is the
+// line terminator to write, "" to preserve the input's.
+func runTranscode(dfPath, from, to, outputPath, eol, backup string) error {
+	log.Debug().Str("df", dfPath).Str("from", from).Str("to", to).Str("output", outputPath).Msg("transcode started")
+
+	in, err := openInput(dfPath)
+	if err != nil {
+		return fmt.Errorf("reading df file: %w", err)
+	}
+	defer in.Close()
+
+	diags := newDiagnostics()
+	opts := diags.options()
+	opts.Codepage = from
+	opts.EOL = eol
+	var res *schemafixer.TranscodeResult
+	err = replaceFile(outputPath, fileMode(outputPath), backup, func(w io.Writer) error {
+		var err error
+		if res, err = schemafixer.Transcode(context.Background(), in, w, to, opts); err != nil {
+			return err
+		}
+		return diags.err()
+	}, in)
+	if err != nil {
+		return fmt.Errorf("transcoding df file: %w", err)
+	}
+
+	log.Info().Str("file", dfPath).Str("from", res.From).Str("to", res.To).Msg("transcoded")
+	return nil
+}
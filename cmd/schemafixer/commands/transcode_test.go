@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRunTranscode_NoTrailer(t *testing.T) {
+	dir := t.TempDir()
+	dfPath := filepath.Join(dir, "schema.df")
+	if err := os.WriteFile(dfPath, []byte("ADD TABLE \"Item\"\n  AREA \"Schema Area\"\n\n"), 0o644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	out := filepath.Join(dir, "out.df")
+
+	// Without a trailer the output can't declare its codepage: a warning.
+	if err := runTranscode(dfPath, "", "ISO8859-1", out, "", ""); err != nil {
+		t.Fatalf("runTranscode() error = %v", err)
+	}
+
+	viper.Set("strict", true)
+	defer viper.Set("strict", false)
+	if err := os.Remove(out); err != nil {
+		t.Fatalf("removing output: %v", err)
+	}
+	if err := runTranscode(dfPath, "", "ISO8859-1", out, "", ""); err == nil {
+		t.Fatal("runTranscode() error = nil, want an error with --strict")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output written despite the error: %v", err)
+	}
+}
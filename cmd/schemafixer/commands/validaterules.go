@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bfv/schemafixer"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// NewValidateRulesCmd builds and returns the 'validate-rules' cobra command.
+func NewValidateRulesCmd() *cobra.Command {
+	var env, codepage string
+
+	cmd := &cobra.Command{
+		Use:   "validate-rules <rules.yaml> <schema.df|->",
+		Short: "Check that the tables, indexes and LOB fields named by a rules file exist in a .df schema",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runValidateRules(cmd, args[0], args[1], env, codepage)
+		},
+	}
+
+	addEnvFlag(cmd, &env)
+	addCodepageFlag(cmd, &codepage)
+	return cmd
+}
+
+// runValidateRules is the entry point for the validate-rules command.
+// Problems are printed to stdout as "file:line:col: severity: message";
+// errors, and warnings with --strict, make the command fail.
+func runValidateRules(cmd *cobra.Command, rulesPath, dfPath, env, codepage string) error {
+	log.Debug().Str("rules", rulesPath).Str("df", dfPath).Msg("validate-rules started")
+
+	rules, err := loadRules(rulesPath, env, nil)
+	if err != nil {
+		return err
+	}
+
+	in, err := openInput(dfPath)
+	if err != nil {
+		return fmt.Errorf("reading df file: %w", err)
+	}
+	defer in.Close()
+
+	opts := apiOptions()
+	opts.Codepage = codepage
+	res, err := schemafixer.ValidateRules(context.Background(), in, rules, opts)
+	if err != nil {
+		return fmt.Errorf("validating rules: %w", err)
+	}
+
+	diags := newDiagnostics()
+	diags.w = cmd.OutOrStdout()
+	for _, p := range res.Problems {
+		diags.report(p)
+	}
+	if err := diags.err(); err != nil {
+		return fmt.Errorf("%s: %w", rulesPath, err)
+	}
+
+	log.Info().Str("rules", rulesPath).Str("df", dfPath).Msg("rules valid")
+	return nil
+}
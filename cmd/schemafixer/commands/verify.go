@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bfv/schemafixer"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// NewVerifyCmd builds and returns the 'verify' cobra command.
+func NewVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <file.df|->",
+		Short: "Check the trailer, codepage and byte count of a .df schema file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(cmd, args[0])
+		},
+	}
+	return cmd
+}
+
+// runVerify is the entry point for the verify command. Problems are printed
+// to stdout as "file:line:col: severity: message"; errors, and warnings with
+// --strict, make the command fail.
+func runVerify(cmd *cobra.Command, dfPath string) error {
+	log.Debug().Str("df", dfPath).Msg("verify started")
+
+	in, err := openInput(dfPath)
+	if err != nil {
+		return fmt.Errorf("reading df file: %w", err)
+	}
+	defer in.Close()
+
+	res, err := schemafixer.Verify(context.Background(), in, apiOptions())
+	if err != nil {
+		return fmt.Errorf("verifying df file: %w", err)
+	}
+
+	diags := newDiagnostics()
+	diags.w = cmd.OutOrStdout()
+	for _, p := range res.Problems {
+		diags.report(p)
+	}
+	if err := diags.err(); err != nil {
+		return fmt.Errorf("%s: %w", dfPath, err)
+	}
+
+	log.Info().Str("file", dfPath).Str("codepage", res.Codepage).Int64("byteCount", res.ByteCount).Msg("verified")
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/bfv/schemafixer/cmd/schemafixer/commands"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // version is set at build time via -ldflags "-X main.version=x.y.z".
@@ -35,10 +36,19 @@ func main() {
 	}
 
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose (debug) logging")
+	rootCmd.PersistentFlags().Bool("strict", false, "Fail with exit code 1 on warnings as well as errors")
+	if err := viper.BindPFlag("strict", rootCmd.PersistentFlags().Lookup("strict")); err != nil {
+		log.Fatal().Err(err).Msg("binding flags")
+	}
 	rootCmd.AddCommand(commands.NewApplyCmd())
 	rootCmd.AddCommand(commands.NewParseCmd())
 	rootCmd.AddCommand(commands.NewDiffCmd())
 	rootCmd.AddCommand(commands.NewFlattenCmd())
+	rootCmd.AddCommand(commands.NewVerifyCmd())
+	rootCmd.AddCommand(commands.NewTranscodeCmd())
+	rootCmd.AddCommand(commands.NewRulesCmd())
+	rootCmd.AddCommand(commands.NewValidateRulesCmd())
+	rootCmd.AddCommand(commands.NewExplainCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Error().Err(err).Msg("fatal error")
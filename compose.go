@@ -0,0 +1,407 @@
+package schemafixer
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ── Rules file composition ────────────────────────────────────────────────────
+//
+// A rules file may extend a base file and include fragments:
+//
+//	schemafixer:
+//	  extends: ../dba/base.yaml
+//	  include: [tables/*.yaml]
+//
+// Fragments are peers: their tables, rules and environments are combined
+// with those of the including file, and a table or default defined by two of
+// them is a conflict. The including file, together with its fragments, is
+// then merged over the base like an environment overlay (see Environment),
+// so it may override whatever the base defines. Relative paths resolve
+// against the directory of the file that names them.
+
+// source is a location in a rules file.
+type source struct {
+	file string
+	line int
+}
+
+// String returns the location as "file:line".
+func (s source) String() string {
+	if s.file == "" {
+		return fmt.Sprintf("line %d", s.line)
+	}
+	return fmt.Sprintf("%s:%d", s.file, s.line)
+}
+
+// loader loads a rules file with the files it extends and includes.
+type loader struct {
+	stack  []frame         // files being loaded, outermost first
+	loaded map[string]bool // absolute paths of the fragments already included
+}
+
+// frame is a file being loaded and where it was named.
+type frame struct {
+	name string // as named
+	path string // absolute
+	via  source // the extends or include entry; zero for the top file
+}
+
+// load reads the rules file at path, named at via.
+func (l *loader) load(path string, via source) (*SchemaFixerRules, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range l.stack {
+		if f.path != abs {
+			continue
+		}
+		chain := []string{f.name}
+		for _, g := range append(l.stack[i+1:], frame{name: path, via: via}) {
+			chain = append(chain, fmt.Sprintf("%s (%s)", g.name, g.via))
+		}
+		return nil, fmt.Errorf("%s: cycle: %s", via, strings.Join(chain, " → "))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if via.file != "" {
+			return nil, fmt.Errorf("%s: %w", via, err)
+		}
+		return nil, err
+	}
+
+	l.stack = append(l.stack, frame{path, abs, via})
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	return l.read(data, path, filepath.Dir(path))
+}
+
+// read decodes one rules file named name and composes it with the files
+// it extends and includes, resolved against dir.
+func (l *loader) read(data []byte, name, dir string) (*SchemaFixerRules, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	version, err := checkVersion(&doc, name)
+	if err != nil {
+		return nil, err
+	}
+	// Decode again, as Node.Decode cannot reject unknown keys.
+	var rf RulesFile
+	if doc.Kind != 0 {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&rf); err != nil {
+			err = decodeError(name, err)
+			if version > RulesVersion {
+				err = fmt.Errorf("%w\n(the rules are version %s; this schemafixer reads up to %s)", err, formatVersion(version), formatVersion(RulesVersion))
+			}
+			return nil, err
+		}
+	}
+	r := &rf.SchemaFixer
+	r.upgrade()
+	annotate(&doc, name, r)
+	if err := r.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	for i, pat := range r.Include {
+		via := r.pos[fmt.Sprintf("include[%d]", i)]
+		paths, err := filepath.Glob(resolve(dir, pat))
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %q: %w", via, pat, err)
+		}
+		if len(paths) == 0 && !hasMeta(pat) {
+			paths = []string{resolve(dir, pat)} // let load report the missing file
+		}
+		for _, p := range paths {
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return nil, err
+			}
+			if l.loaded[abs] && !slices.ContainsFunc(l.stack, func(f frame) bool { return f.path == abs }) {
+				continue // a fragment included by two files is read once
+			}
+			frag, err := l.load(p, via)
+			if err != nil {
+				return nil, err
+			}
+			if l.loaded == nil {
+				l.loaded = map[string]bool{}
+			}
+			l.loaded[abs] = true
+			if err := combine(r, frag); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.Include = nil
+
+	if r.Extends != "" {
+		base, err := l.load(resolve(dir, r.Extends), r.pos["extends"])
+		if err != nil {
+			return nil, err
+		}
+		r = extend(base, r)
+	}
+	return r, nil
+}
+
+// resolve returns path relative to dir, unless it is absolute.
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// hasMeta reports whether path is a glob.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// extend returns top merged over base.
+func extend(base, top *SchemaFixerRules) *SchemaFixerRules {
+	layer := merge(base.layer(), top.layer())
+	out := &SchemaFixerRules{
+		Version:  base.Version,
+		Defaults: layer.Defaults,
+		Tables:   layer.Tables,
+		Rules:    layer.Rules,
+		Groups:   layer.Groups,
+		AreaMap:  layer.AreaMap,
+		MapOrder: base.MapOrder,
+		Areas:    layer.Areas,
+		pos:      layer.pos,
+	}
+	if top.Version != 0 {
+		out.Version = top.Version
+	}
+	if top.MapOrder != "" {
+		out.MapOrder = top.MapOrder
+	}
+	names := slices.Sorted(maps.Keys(base.Environments))
+	for _, name := range slices.Sorted(maps.Keys(top.Environments)) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if out.Environments == nil {
+			out.Environments = map[string]Environment{}
+		}
+		out.Environments[name] = merge(base.Environments[name], top.Environments[name])
+	}
+	return out
+}
+
+// combine adds the fragment frag to r. A table defined in both, or a default
+// they set to different areas, is a conflict.
+func combine(r, frag *SchemaFixerRules) error {
+	if r.Version == 0 {
+		r.Version = frag.Version
+	}
+	switch {
+	case frag.MapOrder == "":
+	case r.MapOrder == "":
+		r.MapOrder = frag.MapOrder
+	case !strings.EqualFold(r.MapOrder, frag.MapOrder):
+		return fmt.Errorf("%s: mapOrder %q conflicts with %q at %s", frag.pos["mapOrder"], frag.MapOrder, r.MapOrder, r.pos["mapOrder"])
+	}
+	layer := r.layer()
+	if err := combineLayer(&layer, frag.layer(), ""); err != nil {
+		return err
+	}
+	r.Defaults, r.Tables, r.Rules, r.Groups, r.AreaMap, r.Areas, r.pos = layer.Defaults, layer.Tables, layer.Rules, layer.Groups, layer.AreaMap, layer.Areas, layer.pos
+
+	for _, name := range slices.Sorted(maps.Keys(frag.Environments)) {
+		env := r.Environments[name]
+		if err := combineLayer(&env, frag.Environments[name], "environments."+name+"."); err != nil {
+			return err
+		}
+		if r.Environments == nil {
+			r.Environments = map[string]Environment{}
+		}
+		r.Environments[name] = env
+	}
+	return nil
+}
+
+// combineLayer adds the tables, groups, rules, defaults, area map and areas
+// catalog of frag to dst. prefix qualifies the names in conflict messages, e.g.
+// "environments.prod.".
+func combineLayer(dst *Environment, frag Environment, prefix string) error {
+	for _, d := range defaultFields(&dst.Defaults, &frag.Defaults) {
+		switch {
+		case *d.src == "":
+		case *d.dst == "":
+			*d.dst = *d.src
+			dst.setPos(d.key, frag.pos[d.key])
+		case *d.dst != *d.src:
+			return fmt.Errorf("%s: %s%s %q conflicts with %q at %s", frag.pos[d.key], prefix, d.key, *d.src, *d.dst, dst.pos[d.key])
+		}
+	}
+
+	for _, t := range frag.Tables {
+		i := slices.IndexFunc(dst.Tables, func(d TableRule) bool { return strings.EqualFold(d.Name, t.Name) })
+		if i >= 0 {
+			return fmt.Errorf("%s: %stable %q is already defined at %s", t.src, prefix, t.Name, dst.Tables[i].src)
+		}
+		dst.Tables = append(dst.Tables, t)
+	}
+	for _, g := range frag.Groups {
+		i := slices.IndexFunc(dst.Groups, func(d Group) bool { return strings.EqualFold(d.Name, g.Name) })
+		if i >= 0 {
+			return fmt.Errorf("%s: %sgroup %q is already defined at %s", g.src, prefix, g.Name, dst.Groups[i].src)
+		}
+		dst.Groups = append(dst.Groups, g)
+	}
+	for _, k := range slices.Sorted(maps.Keys(frag.AreaMap)) {
+		v, defined := frag.AreaMap[k], false
+		for d, dv := range dst.AreaMap {
+			if !strings.EqualFold(d, k) {
+				continue
+			}
+			if dv != v {
+				return fmt.Errorf("%s: %sareaMap %q: %q conflicts with %q at %s", frag.pos["areaMap."+k], prefix, k, v, dv, dst.pos["areaMap."+d])
+			}
+			defined = true
+		}
+		if defined {
+			continue
+		}
+		dst.AreaMap = maps.Clone(dst.AreaMap)
+		if dst.AreaMap == nil {
+			dst.AreaMap = map[string]string{}
+		}
+		dst.AreaMap[k] = v
+		dst.setPos("areaMap."+k, frag.pos["areaMap."+k])
+	}
+	for _, a := range frag.Areas {
+		if !slices.ContainsFunc(dst.Areas, func(d string) bool { return strings.EqualFold(d, a) }) {
+			dst.Areas = append(slices.Clip(dst.Areas), a)
+		}
+	}
+	dst.Rules = append(dst.Rules, frag.Rules...)
+	return nil
+}
+
+// setPos records the location of key.
+func (e *Environment) setPos(key string, s source) {
+	e.pos = maps.Clone(e.pos)
+	if e.pos == nil {
+		e.pos = map[string]source{}
+	}
+	e.pos[key] = s
+}
+
+// annotate records where the entries of r are defined in doc, the parsed
+// YAML of the file name.
+func annotate(doc *yaml.Node, name string, r *SchemaFixerRules) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	_, sf := mapEntry(root, "schemafixer")
+	if sf == nil {
+		return
+	}
+	at := func(n *yaml.Node) source { return source{name, n.Line} }
+
+	r.pos = map[string]source{}
+	for i := 0; i+1 < len(sf.Content); i += 2 {
+		r.pos[sf.Content[i].Value] = at(sf.Content[i])
+	}
+	if _, inc := mapEntry(sf, "include"); inc != nil {
+		for i, n := range inc.Content {
+			r.pos[fmt.Sprintf("include[%d]", i)] = at(n)
+		}
+	}
+
+	layer := r.layer()
+	annotateLayer(sf, at, &layer)
+	r.Tables, r.Rules, r.Groups, r.pos = layer.Tables, layer.Rules, layer.Groups, layer.pos
+
+	_, envs := mapEntry(sf, "environments")
+	for name, env := range r.Environments {
+		_, n := mapEntry(envs, name)
+		annotateLayer(n, at, &env)
+		r.Environments[name] = env
+	}
+}
+
+// annotateLayer records the locations of the defaults, tables and rules of
+// the mapping n in e.
+func annotateLayer(n *yaml.Node, at func(*yaml.Node) source, e *Environment) {
+	for _, section := range []string{"defaults", "areaMap"} {
+		_, d := mapEntry(n, section)
+		for i := 0; d != nil && i+1 < len(d.Content); i += 2 {
+			e.setPos(section+"."+d.Content[i].Value, at(d.Content[i]))
+		}
+	}
+	if _, ts := mapEntry(n, "tables"); ts != nil {
+		for i, tn := range ts.Content {
+			if i >= len(e.Tables) {
+				break
+			}
+			t := &e.Tables[i]
+			t.src = at(tn)
+			t.pos = map[string]source{}
+			for _, key := range []string{"area", "indexArea", "lobArea"} {
+				if k, _ := mapEntry(tn, key); k != nil {
+					t.pos[key] = at(k)
+				}
+			}
+			for _, kind := range []string{"indexes", "lobs"} {
+				_, m := mapEntry(tn, kind)
+				for j := 0; m != nil && j+1 < len(m.Content); j += 2 {
+					t.pos[kind+"."+m.Content[j].Value] = at(m.Content[j])
+				}
+			}
+		}
+	}
+	if _, gs := mapEntry(n, "groups"); gs != nil {
+		for i, gn := range gs.Content {
+			if i >= len(e.Groups) {
+				break
+			}
+			g := &e.Groups[i]
+			g.src = at(gn)
+			g.pos = map[string]source{}
+			for _, key := range []string{"area", "indexArea", "lobArea"} {
+				if k, _ := mapEntry(gn, key); k != nil {
+					g.pos[key] = at(k)
+				}
+			}
+		}
+	}
+	if _, rs := mapEntry(n, "rules"); rs != nil {
+		for i, rn := range rs.Content {
+			if i < len(e.Rules) {
+				e.Rules[i].src = at(rn)
+			}
+		}
+	}
+}
+
+// mapEntry returns the key and value nodes of key in the mapping m, or nils.
+func mapEntry(m *yaml.Node, key string) (k, v *yaml.Node) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
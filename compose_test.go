@@ -0,0 +1,151 @@
+package schemafixer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files, keyed by path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadRules_Compose(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"dba/base.yaml": `schemafixer:
+  version: 1.0
+  defaults:
+    table: DataArea
+    index: IndexArea
+    lob: LobArea
+  tables:
+    - name: customer
+      area: CustData
+      indexes:
+        custnum: CustIdx
+  environments:
+    prod:
+      defaults:
+        table: ProdData
+`,
+		"app/rules.yaml": `schemafixer:
+  extends: ../dba/base.yaml
+  include: [tables/*.yaml]
+  defaults:
+    index: AppIdx
+  tables:
+    - name: customer
+      indexes:
+        name: NameIdx
+`,
+		"app/tables/order.yaml": `schemafixer:
+  tables:
+    - name: order
+      area: Orders
+  rules:
+    - when: index.word
+      area: WordIdx
+`,
+		"app/tables/item.yaml": `schemafixer:
+  tables:
+    - name: item
+      area: Items
+`,
+	})
+
+	rf, err := LoadRules(filepath.Join(dir, "app/rules.yaml"))
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	r := &rf.SchemaFixer
+	area := areaOf(t)
+	checks := []struct{ got, want string }{
+		{r.Defaults.Table, "DataArea"},
+		{r.Defaults.Index, "AppIdx"},
+		{r.TableArea("customer"), "CustData"},
+		{r.IndexArea("customer", "custnum"), "CustIdx"},
+		{r.IndexArea("customer", "name"), "NameIdx"},
+		{r.TableArea("order"), "Orders"},
+		{r.TableArea("item"), "Items"},
+		{area(r.IndexAreaFor(&TableInfo{Name: "x"}, &IndexInfo{Table: "x", Name: "w", Word: true})), "WordIdx"},
+		{r.Extends, ""},
+	}
+	for i, c := range checks {
+		if c.got != c.want {
+			t.Errorf("check %d: got %q, want %q", i, c.got, c.want)
+		}
+	}
+	if len(r.Include) != 0 {
+		t.Errorf("Include = %v, want it resolved", r.Include)
+	}
+	prod, err := r.Environment("prod")
+	if err != nil || prod.Defaults.Table != "ProdData" {
+		t.Errorf("Environment(prod) = %v, %v", prod, err)
+	}
+}
+
+func TestLoadRules_ComposeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a.yaml": "schemafixer:\n  extends: b.yaml\n",
+				"b.yaml": "schemafixer:\n  include:\n    - a.yaml\n",
+			},
+			want: []string{"b.yaml:3: cycle: ", "a.yaml → ", "b.yaml (", "a.yaml:2) → ", "a.yaml (", "b.yaml:3)"},
+		},
+		{
+			name: "conflicting table",
+			files: map[string]string{
+				"a.yaml":   "schemafixer:\n  include: [t/*.yaml]\n",
+				"t/1.yaml": "schemafixer:\n  tables:\n    - name: customer\n      area: A\n",
+				"t/2.yaml": "schemafixer:\n  tables:\n    - name: Customer\n      area: B\n",
+			},
+			want: []string{"t/2.yaml:3: table \"Customer\" is already defined at ", "t/1.yaml:3"},
+		},
+		{
+			name: "conflicting default",
+			files: map[string]string{
+				"a.yaml": "schemafixer:\n  defaults:\n    table: A\n  include: [b.yaml]\n",
+				"b.yaml": "schemafixer:\n  defaults:\n    table: B\n",
+			},
+			want: []string{"b.yaml:3: defaults.table \"B\" conflicts with \"A\" at ", "a.yaml:3"},
+		},
+		{
+			name:  "missing include",
+			files: map[string]string{"a.yaml": "schemafixer:\n  include:\n    - missing.yaml\n"},
+			want:  []string{"a.yaml:3: ", "missing.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			_, err := LoadRules(filepath.Join(dir, "a.yaml"))
+			if err == nil {
+				t.Fatal("LoadRules() error = nil")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("LoadRules() error = %v, want it to contain %q", err, w)
+				}
+			}
+		})
+	}
+}
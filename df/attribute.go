@@ -0,0 +1,227 @@
+package df
+
+import "strings"
+
+// TokenKind classifies a token on an attribute or header line.
+type TokenKind int
+
+const (
+	Word   TokenKind = iota // bare word, number or the unknown value ?
+	String                  // double-quoted string literal
+)
+
+// Token is a single word or quoted string.
+type Token struct {
+	Kind  TokenKind
+	Raw   string // text as written, including quotes and "" escapes
+	Value string // unquoted value for strings, Raw for words
+	Pos   Pos
+
+	// Unterminated is set for a string whose closing quote is missing.
+	Unterminated bool
+
+	off int // byte offset into the attribute's logical text
+}
+
+// Attribute is one logical attribute of a statement: a keyword followed by
+// its arguments. A quoted string may span several physical lines, e.g. a
+// multi-line DESCRIPTION or VALEXP, in which case the attribute owns all of
+// them.
+type Attribute struct {
+	Keyword string // as written, e.g. "AREA"
+	Args    []Token
+
+	lines []*Line
+}
+
+// newAttribute tokenizes lines into an attribute.
+func newAttribute(lines []*Line) *Attribute {
+	a := &Attribute{lines: lines}
+	a.tokenize()
+	return a
+}
+
+// NewAttribute builds an attribute line from its source text, e.g.
+// `  AREA "Data Area"`. The line is given the terminator eol.
+func NewAttribute(text, eol string) *Attribute {
+	var lines []*Line
+	for _, t := range strings.Split(text, "\n") {
+		lines = append(lines, &Line{Text: t, EOL: eol})
+	}
+	return newAttribute(lines)
+}
+
+// Pos returns the position of the attribute's first line.
+func (a *Attribute) Pos() Pos {
+	return Pos{Line: a.lines[0].Num, Col: 1}
+}
+
+// Lines returns the physical lines of the attribute.
+func (a *Attribute) Lines() []*Line { return a.lines }
+
+// Text returns the logical text of the attribute: its lines joined by "\n".
+func (a *Attribute) Text() string {
+	if len(a.lines) == 1 {
+		return a.lines[0].Text
+	}
+	parts := make([]string, len(a.lines))
+	for i, l := range a.lines {
+		parts[i] = l.Text
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Is reports whether the attribute has the given keyword (case-insensitive).
+func (a *Attribute) Is(keyword string) bool {
+	return strings.EqualFold(a.Keyword, keyword)
+}
+
+// Value returns the value of the first argument, or "" if there is none.
+func (a *Attribute) Value() string {
+	if len(a.Args) == 0 {
+		return ""
+	}
+	return a.Args[0].Value
+}
+
+// SetValue replaces the first argument with v as a quoted string, or appends
+// it if the attribute has no arguments. Everything else on the line,
+// including trailing whitespace, is kept.
+func (a *Attribute) SetValue(v string) {
+	text := a.Text()
+	quoted := Quote(v)
+	if len(a.Args) == 0 {
+		text += " " + quoted
+	} else {
+		tok := a.Args[0]
+		text = text[:tok.off] + quoted + text[tok.off+len(tok.Raw):]
+	}
+	a.setText(text)
+}
+
+// setText replaces the logical text, reusing the existing lines (and so
+// their numbers and terminators) where possible.
+func (a *Attribute) setText(text string) {
+	parts := strings.Split(text, "\n")
+	old := a.lines
+	lines := make([]*Line, len(parts))
+	for i, p := range parts {
+		l := &Line{Text: p, EOL: old[0].EOL}
+		if i < len(old) {
+			l.Num = old[i].Num
+			if i < len(old)-1 {
+				l.EOL = old[i].EOL
+			}
+		}
+		lines[i] = l
+	}
+	// The last line keeps the attribute's original terminator, which may be
+	// "" at the end of the file.
+	lines[len(lines)-1].EOL = old[len(old)-1].EOL
+	a.lines = lines
+	a.tokenize()
+}
+
+// tokenize splits the logical text into the keyword and argument tokens.
+func (a *Attribute) tokenize() {
+	toks, _ := scan(a.Text())
+	for i := range toks {
+		toks[i].Pos = a.posAt(toks[i].off)
+	}
+	a.Keyword = ""
+	a.Args = toks
+	if len(toks) > 0 && toks[0].Kind == Word {
+		a.Keyword = toks[0].Raw
+		a.Args = toks[1:]
+	}
+}
+
+// posAt converts a byte offset in the logical text to a source position.
+func (a *Attribute) posAt(off int) Pos {
+	for _, l := range a.lines {
+		if off <= len(l.Text) {
+			return Pos{Line: l.Num, Col: off + 1}
+		}
+		off -= len(l.Text) + 1
+	}
+	last := a.lines[len(a.lines)-1]
+	return Pos{Line: last.Num, Col: len(last.Text) + 1}
+}
+
+// scan splits text into tokens. open reports whether text ends inside a
+// quoted string, i.e. the string continues on the next line.
+func scan(text string) (toks []Token, open bool) {
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			start := i
+			i++
+			closed := false
+			for i < len(text) {
+				if text[i] == '"' {
+					if i+1 < len(text) && text[i+1] == '"' {
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				i++
+			}
+			raw := text[start:i]
+			toks = append(toks, Token{
+				Kind:         String,
+				Raw:          raw,
+				Value:        Unquote(raw),
+				Unterminated: !closed,
+				off:          start,
+			})
+			if !closed {
+				return toks, true
+			}
+		default:
+			start := i
+			for i < len(text) && !strings.ContainsRune(" \t\n\r\"", rune(text[i])) {
+				i++
+			}
+			toks = append(toks, Token{Kind: Word, Raw: text[start:i], Value: text[start:i], off: start})
+		}
+	}
+	return toks, false
+}
+
+// Quote returns s as a .df string literal, doubling embedded quotes.
+func Quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// Unquote reverses Quote. A missing closing quote is tolerated.
+func Unquote(raw string) string {
+	s := strings.TrimPrefix(raw, `"`)
+	if closesString(raw) {
+		s = s[:len(s)-1]
+	}
+	return strings.ReplaceAll(s, `""`, `"`)
+}
+
+// closesString reports whether raw, which starts with a quote, ends with an
+// unescaped closing quote.
+func closesString(raw string) bool {
+	i := 1
+	for i < len(raw) {
+		if raw[i] == '"' {
+			if i+1 < len(raw) && raw[i+1] == '"' {
+				i += 2
+				continue
+			}
+			return i == len(raw)-1
+		}
+		i++
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package df
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// codepages maps the OpenEdge codepage names that can appear as the cpstream
+// setting of a .df trailer to their character encodings.
+var codepages = []struct {
+	name string
+	enc  encoding.Encoding
+}{
+	{"UTF-8", unicode.UTF8},
+	{"ISO8859-1", charmap.ISO8859_1},
+	{"ISO8859-2", charmap.ISO8859_2},
+	{"ISO8859-3", charmap.ISO8859_3},
+	{"ISO8859-4", charmap.ISO8859_4},
+	{"ISO8859-5", charmap.ISO8859_5},
+	{"ISO8859-6", charmap.ISO8859_6},
+	{"ISO8859-7", charmap.ISO8859_7},
+	{"ISO8859-8", charmap.ISO8859_8},
+	{"ISO8859-9", charmap.ISO8859_9},
+	{"ISO8859-10", charmap.ISO8859_10},
+	{"ISO8859-13", charmap.ISO8859_13},
+	{"ISO8859-14", charmap.ISO8859_14},
+	{"ISO8859-15", charmap.ISO8859_15},
+	{"620-2533", charmap.Windows874},
+	{"1250", charmap.Windows1250},
+	{"1251", charmap.Windows1251},
+	{"1252", charmap.Windows1252},
+	{"1253", charmap.Windows1253},
+	{"1254", charmap.Windows1254},
+	{"1255", charmap.Windows1255},
+	{"1256", charmap.Windows1256},
+	{"1257", charmap.Windows1257},
+	{"1258", charmap.Windows1258},
+	{"IBM437", charmap.CodePage437},
+	{"IBM850", charmap.CodePage850},
+	{"IBM852", charmap.CodePage852},
+	{"IBM855", charmap.CodePage855},
+	{"IBM858", charmap.CodePage858},
+	{"IBM860", charmap.CodePage860},
+	{"IBM862", charmap.CodePage862},
+	{"IBM863", charmap.CodePage863},
+	{"IBM865", charmap.CodePage865},
+	{"IBM866", charmap.CodePage866},
+	{"KOI8-R", charmap.KOI8R},
+	{"KOI8-U", charmap.KOI8U},
+	{"SHIFT-JIS", japanese.ShiftJIS},
+	{"EUCJIS", japanese.EUCJP},
+	{"BIG-5", traditionalchinese.Big5},
+	{"GB2312", simplifiedchinese.GBK},
+	{"GB18030", simplifiedchinese.GB18030},
+	{"KSC5601", korean.EUCKR},
+}
+
+// KnownCodepage reports whether name is a codepage OpenEdge writes into
+// the cpstream setting. Names are compared case-insensitively.
+func KnownCodepage(name string) bool {
+	_, ok := Codepage(name)
+	return ok
+}
+
+// Codepage returns the character encoding of the OpenEdge codepage name, or
+// false if the name is unknown. UTF-8 yields a nil encoding: lines are then
+// passed through as read.
+func Codepage(name string) (encoding.Encoding, bool) {
+	for _, cp := range codepages {
+		if strings.EqualFold(cp.name, name) {
+			if cp.enc == unicode.UTF8 {
+				return nil, true
+			}
+			return cp.enc, true
+		}
+	}
+	return nil, false
+}
+
+// tailSize is how much of the end of a file DetectCodepage reads; trailers
+// are a handful of short lines.
+const tailSize = 4096
+
+// DetectCodepage reads the cpstream setting from the trailer at the end of
+// rs and seeks back to where rs was. It returns "" if the tail has no
+// cpstream setting. The trailer is plain ASCII in every codepage.
+func DetectCodepage(rs io.ReadSeeker) (string, error) {
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	off := max(start, end-tailSize)
+	if _, err := rs.Seek(off, io.SeekStart); err != nil {
+		return "", err
+	}
+	tail, err := io.ReadAll(rs)
+	if err != nil {
+		return "", err
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	// The last cpstream line wins, as it does in the trailer itself.
+	name := ""
+	for _, line := range bytes.Split(tail, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if k, v, ok := bytes.Cut(line, []byte("=")); ok && strings.EqualFold(string(k), "cpstream") {
+			name = string(v)
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,313 @@
+// Package df implements a lossless syntax tree for OpenEdge .df schema files.
+//
+// A .df file is a sequence of statements (ADD SEQUENCE, ADD TABLE, ADD FIELD,
+// ADD INDEX, ...). Incremental .df files produced by the Data Dictionary
+// also contain UPDATE, DROP and RENAME statements. Each statement is a header line followed by indented
+// attribute lines and is terminated by a blank line. The file usually ends
+// with a "."-delimited PSC trailer and a 10-digit byte count.
+//
+// Parse keeps every source line together with its line terminator, so a File
+// that is not modified is written back byte-for-byte by File.WriteTo.
+package df
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Line is a single physical line of a .df source.
+type Line struct {
+	Num  int    // 1-based line number in the source, 0 for inserted lines
+	Text string // content without the line terminator
+	EOL  string // "\n", "\r\n", or "" for a final line without terminator
+}
+
+// Pos is a position in a .df source.
+type Pos struct {
+	Line int // 1-based line number
+	Col  int // 1-based byte column
+}
+
+// String renders the position as "line:col".
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Node is a top-level element of a .df file: a statement, a run of blank
+// lines, stray lines outside any statement, or the trailer.
+type Node interface {
+	// Pos returns the position of the node's first line.
+	Pos() Pos
+	// Lines returns the node's source lines in order.
+	Lines() []*Line
+}
+
+// Statement is implemented by every statement node (*Table, *Field, *Index,
+// *Sequence, *Update, *Drop, *Rename and *Other).
+type Statement interface {
+	Node
+	// Base returns the shared statement data.
+	Base() *Stmt
+}
+
+// Stmt holds what all statements have in common: the header line, tokenized
+// like an attribute whose keyword is the verb, and the attribute lines up to
+// the terminating blank line.
+type Stmt struct {
+	Header *Attribute
+	Attrs  []*Attribute
+}
+
+// Base returns s itself; it lets typed nodes satisfy Statement.
+func (s *Stmt) Base() *Stmt { return s }
+
+// Pos returns the position of the header line.
+func (s *Stmt) Pos() Pos { return s.Header.Pos() }
+
+// Lines returns the header line followed by all attribute lines.
+func (s *Stmt) Lines() []*Line {
+	lines := append([]*Line(nil), s.Header.lines...)
+	for _, a := range s.Attrs {
+		lines = append(lines, a.lines...)
+	}
+	return lines
+}
+
+// Verb returns the upper-cased statement verb, e.g. "ADD".
+func (s *Stmt) Verb() string {
+	return strings.ToUpper(s.Header.Keyword)
+}
+
+// modifiers are the words that may precede the object type, as in
+// UPDATE PRIMARY INDEX.
+var modifiers = map[string]bool{"PRIMARY": true, "INACTIVE": true}
+
+// Object returns the upper-cased object type, e.g. "TABLE" or "INDEX".
+// Modifiers such as the PRIMARY of UPDATE PRIMARY INDEX are skipped.
+func (s *Stmt) Object() string {
+	if i := s.object(); i >= 0 {
+		return strings.ToUpper(s.Header.Args[i].Value)
+	}
+	return ""
+}
+
+// object returns the index of the object type among the header arguments,
+// or -1 if there is none.
+func (s *Stmt) object() int {
+	for i, a := range s.Header.Args {
+		if a.Kind != Word {
+			break
+		}
+		if !modifiers[strings.ToUpper(a.Value)] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Attr returns the first attribute with the given keyword (case-insensitive),
+// or nil if there is none.
+func (s *Stmt) Attr(keyword string) *Attribute {
+	for _, a := range s.Attrs {
+		if a.Is(keyword) {
+			return a
+		}
+	}
+	return nil
+}
+
+// AttrValue returns the value of the first attribute with the given keyword,
+// or "" if there is none.
+func (s *Stmt) AttrValue(keyword string) string {
+	if a := s.Attr(keyword); a != nil {
+		return a.Value()
+	}
+	return ""
+}
+
+// HasAttr reports whether the statement has an attribute with the given
+// keyword.
+func (s *Stmt) HasAttr(keyword string) bool {
+	return s.Attr(keyword) != nil
+}
+
+// AttrIndex returns the index in Attrs of the first attribute with any of
+// the given keywords, or -1 if there is none.
+func (s *Stmt) AttrIndex(keywords ...string) int {
+	for i, a := range s.Attrs {
+		for _, kw := range keywords {
+			if a.Is(kw) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// InsertAttr inserts a new attribute line with the given text, e.g.
+// `  AREA "Data Area"`, at index i of Attrs; 0 puts it directly after the
+// header. The line gets the terminator used by the statement. Inserting
+// after a final line without terminator moves that missing terminator to
+// the new line.
+func (s *Stmt) InsertAttr(i int, text string) *Attribute {
+	lines := s.Lines()
+	eol := "\n"
+	for _, l := range lines {
+		if l.EOL != "" {
+			eol = l.EOL
+			break
+		}
+	}
+	a := NewAttribute(text, eol)
+	if last := lines[len(lines)-1]; i == len(s.Attrs) && last.EOL == "" {
+		last.EOL = eol
+		a.lines[len(a.lines)-1].EOL = ""
+	}
+	s.Attrs = append(s.Attrs, nil)
+	copy(s.Attrs[i+1:], s.Attrs[i:])
+	s.Attrs[i] = a
+	return a
+}
+
+// RemoveAttrs deletes every attribute for which drop returns true and
+// returns the number of attributes removed.
+func (s *Stmt) RemoveAttrs(drop func(*Attribute) bool) int {
+	return removeAttrs(&s.Attrs, drop)
+}
+
+func removeAttrs(attrs *[]*Attribute, drop func(*Attribute) bool) int {
+	kept := (*attrs)[:0]
+	removed := 0
+	for _, a := range *attrs {
+		if drop(a) {
+			removed++
+			continue
+		}
+		kept = append(kept, a)
+	}
+	*attrs = kept
+	return removed
+}
+
+// Sequence is an ADD SEQUENCE statement.
+type Sequence struct {
+	Stmt
+	Name string
+}
+
+// Table is an ADD TABLE statement.
+type Table struct {
+	Stmt
+	Name string
+}
+
+// Area returns the value of the table's AREA attribute.
+func (t *Table) Area() string { return t.AttrValue("AREA") }
+
+// Field is an ADD FIELD statement.
+type Field struct {
+	Stmt
+	Name     string
+	Table    string
+	DataType string // as written after AS, e.g. "character" or "blob"
+}
+
+// IsLob reports whether the field is a BLOB or CLOB field.
+func (f *Field) IsLob() bool {
+	return strings.EqualFold(f.DataType, "blob") || strings.EqualFold(f.DataType, "clob")
+}
+
+// LobArea returns the value of the field's LOB-AREA attribute.
+func (f *Field) LobArea() string { return f.AttrValue("LOB-AREA") }
+
+// Index is an ADD INDEX statement.
+type Index struct {
+	Stmt
+	Name  string
+	Table string
+}
+
+// Area returns the value of the index's AREA attribute.
+func (ix *Index) Area() string { return ix.AttrValue("AREA") }
+
+// Unique reports whether the index has the UNIQUE attribute.
+func (ix *Index) Unique() bool { return ix.HasAttr("UNIQUE") }
+
+// Primary reports whether the index has the PRIMARY attribute.
+func (ix *Index) Primary() bool { return ix.HasAttr("PRIMARY") }
+
+// Word reports whether the index has the WORD attribute.
+func (ix *Index) Word() bool { return ix.HasAttr("WORD") }
+
+// Update is an UPDATE statement of an incremental .df. It changes the
+// attributes of an existing object, e.g. UPDATE FIELD "Name" OF "Customer"
+// or UPDATE PRIMARY INDEX "CustNum" ON "Customer".
+type Update struct {
+	Stmt
+	Name  string
+	Table string // owning table of a field or index, "" otherwise
+}
+
+// Drop is a DROP statement of an incremental .df, e.g.
+// DROP INDEX "Comments" ON "Customer".
+type Drop struct {
+	Stmt
+	Name  string
+	Table string // owning table of a field or index, "" otherwise
+}
+
+// Rename is a RENAME statement of an incremental .df, e.g.
+// RENAME FIELD "Name" OF "Customer" TO "FullName".
+type Rename struct {
+	Stmt
+	Name    string
+	Table   string // owning table of a field or index, "" otherwise
+	NewName string
+}
+
+// Other is a statement this package does not model, e.g. ADD DATABASE.
+type Other struct {
+	Stmt
+}
+
+// Blank is a run of empty or whitespace-only lines.
+type Blank struct {
+	lines []*Line
+}
+
+// Pos returns the position of the first blank line.
+func (b *Blank) Pos() Pos { return Pos{Line: b.lines[0].Num, Col: 1} }
+
+// Lines returns the blank lines.
+func (b *Blank) Lines() []*Line { return b.lines }
+
+// Raw is a run of non-blank lines outside any statement. Its lines are
+// tokenized like attribute lines so that stray attributes can still be
+// inspected.
+type Raw struct {
+	Attrs []*Attribute
+}
+
+// Pos returns the position of the first line.
+func (r *Raw) Pos() Pos { return r.Attrs[0].Pos() }
+
+// Lines returns all lines of the run.
+func (r *Raw) Lines() []*Line {
+	var lines []*Line
+	for _, a := range r.Attrs {
+		lines = append(lines, a.lines...)
+	}
+	return lines
+}
+
+// RemoveAttrs deletes every line for which drop returns true and returns the
+// number of lines removed.
+func (r *Raw) RemoveAttrs(drop func(*Attribute) bool) int {
+	return removeAttrs(&r.Attrs, drop)
+}
+
+// File is a parsed .df file.
+type File struct {
+	Nodes   []Node
+	Trailer *Trailer // nil if the file has no trailer
+}
@@ -0,0 +1,245 @@
+package df
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+// Parse reads a complete .df file from r into memory. Use a Decoder to
+// process large files one node at a time.
+func Parse(r io.Reader) (*File, error) {
+	d := NewDecoder(r)
+	f := &File{}
+	for {
+		n, err := d.Next()
+		if err == io.EOF {
+			return f, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := n.(*Trailer); ok {
+			f.Trailer = t
+			continue
+		}
+		f.Nodes = append(f.Nodes, n)
+	}
+}
+
+// Decoder reads nodes from a .df stream one at a time, holding no more
+// than the current node in memory. Lines may be of any length and end in LF
+// or CRLF; both are kept as written.
+type Decoder struct {
+	// Encoding, when non-nil, is the character encoding of the source; every
+	// line is decoded to UTF-8 as it is read. See Codepage.
+	Encoding encoding.Encoding
+
+	r    *bufio.Reader
+	dec  *encoding.Decoder
+	num  int
+	peek *Line
+	err  error
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// peekLine returns the next line without consuming it, or nil at the end of
+// the input or on a read error (kept in d.err).
+func (d *Decoder) peekLine() *Line {
+	if d.peek != nil || d.err != nil {
+		return d.peek
+	}
+	text, err := d.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		d.err = err
+		return nil
+	}
+	if text == "" {
+		d.err = io.EOF
+		return nil
+	}
+	d.num++
+	l := &Line{Num: d.num}
+	switch {
+	case strings.HasSuffix(text, "\r\n"):
+		l.Text, l.EOL = text[:len(text)-2], "\r\n"
+	case strings.HasSuffix(text, "\n"):
+		l.Text, l.EOL = text[:len(text)-1], "\n"
+	default:
+		l.Text = text
+	}
+	if d.Encoding != nil {
+		if d.dec == nil {
+			d.dec = d.Encoding.NewDecoder()
+		}
+		if l.Text, err = d.dec.String(l.Text); err != nil {
+			d.err = fmt.Errorf("line %d: %w", l.Num, err)
+			return nil
+		}
+	}
+	d.peek = l
+	return l
+}
+
+// nextLine consumes and returns the next line.
+func (d *Decoder) nextLine() *Line {
+	l := d.peekLine()
+	d.peek = nil
+	return l
+}
+
+// Next returns the next top-level node, or io.EOF after the last one. The
+// trailer, if any, is returned as the final node.
+func (d *Decoder) Next() (Node, error) {
+	l := d.peekLine()
+	if l == nil {
+		return nil, d.err
+	}
+
+	switch {
+	case isTrailerStart(l):
+		var lines []*Line
+		for l := d.nextLine(); l != nil; l = d.nextLine() {
+			lines = append(lines, l)
+		}
+		return newTrailer(lines), d.readErr()
+
+	case isBlank(l):
+		b := &Blank{}
+		for l := d.peekLine(); l != nil && isBlank(l); l = d.peekLine() {
+			b.lines = append(b.lines, d.nextLine())
+		}
+		return b, d.readErr()
+
+	case isHeader(l):
+		header := d.attribute()
+		stmt := Stmt{Header: header}
+		for l := d.peekLine(); l != nil && !endsStatement(l); l = d.peekLine() {
+			stmt.Attrs = append(stmt.Attrs, d.attribute())
+		}
+		return typed(stmt), d.readErr()
+
+	default:
+		raw := &Raw{}
+		for l := d.peekLine(); l != nil && !endsStatement(l); l = d.peekLine() {
+			raw.Attrs = append(raw.Attrs, d.attribute())
+		}
+		return raw, d.readErr()
+	}
+}
+
+// readErr returns a pending read error other than io.EOF; the end of input
+// is reported by the following call to Next.
+func (d *Decoder) readErr() error {
+	if d.err == io.EOF {
+		return nil
+	}
+	return d.err
+}
+
+// attribute consumes one logical attribute, following quoted strings that
+// continue onto the next lines.
+func (d *Decoder) attribute() *Attribute {
+	lines := []*Line{d.nextLine()}
+	text := lines[0].Text
+	for {
+		_, open := scan(text)
+		if !open {
+			break
+		}
+		l := d.nextLine()
+		if l == nil {
+			break
+		}
+		lines = append(lines, l)
+		text += "\n" + l.Text
+	}
+	return newAttribute(lines)
+}
+
+// verbs are the statement verbs that can start a line.
+var verbs = map[string]bool{"ADD": true, "UPDATE": true, "DROP": true, "RENAME": true}
+
+// objects are the object types modelled by typed nodes.
+var objects = map[string]bool{"TABLE": true, "FIELD": true, "INDEX": true, "SEQUENCE": true}
+
+// typed wraps a statement into its typed node based on the header.
+func typed(s Stmt) Statement {
+	args := s.Header.Args
+	obj := s.object()
+	if obj < 0 || obj+1 >= len(args) || !objects[s.Object()] {
+		return &Other{Stmt: s}
+	}
+	name := args[obj+1].Value
+	// keyword looks for kw among the header words after the name and
+	// returns the token after it.
+	keyword := func(kw string) string {
+		for i := obj + 2; i < len(args)-1; i++ {
+			if args[i].Kind == Word && strings.EqualFold(args[i].Value, kw) {
+				return args[i+1].Value
+			}
+		}
+		return ""
+	}
+	// Fields belong to a table through OF, indexes through ON or OF.
+	table := keyword("OF")
+	if table == "" {
+		table = keyword("ON")
+	}
+
+	switch s.Verb() {
+	case "ADD":
+		if obj != 0 {
+			break
+		}
+		switch s.Object() {
+		case "SEQUENCE":
+			return &Sequence{Stmt: s, Name: name}
+		case "TABLE":
+			return &Table{Stmt: s, Name: name}
+		case "FIELD":
+			return &Field{Stmt: s, Name: name, Table: keyword("OF"), DataType: keyword("AS")}
+		case "INDEX":
+			return &Index{Stmt: s, Name: name, Table: keyword("ON")}
+		}
+	case "UPDATE":
+		return &Update{Stmt: s, Name: name, Table: table}
+	case "DROP":
+		return &Drop{Stmt: s, Name: name, Table: table}
+	case "RENAME":
+		return &Rename{Stmt: s, Name: name, Table: table, NewName: keyword("TO")}
+	}
+	return &Other{Stmt: s}
+}
+
+// isHeader reports whether l starts a statement: a known verb in column 1
+// followed by an object type.
+func isHeader(l *Line) bool {
+	toks, _ := scan(l.Text)
+	if len(toks) < 2 || toks[0].off != 0 || toks[0].Kind != Word || toks[1].Kind != Word {
+		return false
+	}
+	return verbs[strings.ToUpper(toks[0].Raw)]
+}
+
+// isBlank reports whether l is empty or contains only whitespace.
+func isBlank(l *Line) bool {
+	return strings.TrimSpace(l.Text) == ""
+}
+
+// isTrailerStart reports whether l is the "." line that opens the trailer.
+func isTrailerStart(l *Line) bool {
+	return l.Text == "."
+}
+
+// endsStatement reports whether l terminates the current statement.
+func endsStatement(l *Line) bool {
+	return isBlank(l) || isHeader(l) || isTrailerStart(l)
+}
@@ -0,0 +1,345 @@
+package df
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, src string) *File {
+	t.Helper()
+	f, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return f
+}
+
+func render(t *testing.T, f *File) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "LF with trailer",
+			input: "ADD TABLE \"Item\"\n  AREA \"Data Area\"\n\n.\nPSC\ncpstream=UTF-8\n.\n0000000031\n",
+		},
+		{
+			name:  "CRLF line endings",
+			input: "ADD TABLE \"Item\"\r\n  AREA \"Data Area\"\r\n\r\n",
+		},
+		{
+			name:  "mixed line endings and no final newline",
+			input: "ADD TABLE \"Item\"\r\n  AREA \"Data Area\"\n\n  DUMP-NAME \"item\"",
+		},
+		{
+			name:  "trailing whitespace on header lines",
+			input: "ADD FIELD \"Name\" OF \"Item\" AS character \n  FORMAT \"x(8)\"  \n\n",
+		},
+		{
+			name:  "multi-line string containing a blank line and a header",
+			input: "ADD TABLE \"Item\"\n  DESCRIPTION \"first\n\nADD TABLE \"\"x\"\"\nlast\"\n  AREA \"Data Area\"\n\n",
+		},
+		{
+			name:  "unterminated string at end of input",
+			input: "ADD TABLE \"Item\"\n  DESCRIPTION \"never closed\n",
+		},
+		{
+			name:  "stray lines outside a statement",
+			input: "  AREA \"Data Area\"\ngarbage\n\nADD TABLE \"Item\"\n",
+		},
+		{
+			name:  "empty input",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := render(t, mustParse(t, tt.input)); got != tt.input {
+				t.Errorf("round trip mismatch\ngot:  %q\nwant: %q", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestParse_RoundTripSchemaFiles(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("..", "schema", "*.df"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no schema fixtures found: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading fixture: %v", err)
+			}
+			f, err := Parse(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := render(t, f); got != string(data) {
+				t.Errorf("round trip of %s is not byte-identical", path)
+			}
+			if f.Trailer == nil {
+				t.Fatal("expected a trailer")
+			}
+			if _, ok := f.Trailer.ByteCount(); !ok || f.Trailer.Codepage() != "UTF-8" {
+				t.Errorf("expected a UTF-8 trailer with byte count")
+			}
+		})
+	}
+}
+
+func TestParse_Nodes(t *testing.T) {
+	src := "ADD SEQUENCE \"NextNum\"\n" +
+		"  INITIAL 10\n" +
+		"\n" +
+		"ADD TABLE \"Item\"\n" +
+		"  AREA \"Data Area\"\n" +
+		"  VALEXP \"NOT (CAN-FIND(FIRST Customer WHERE\n" +
+		"   Customer.Name = \"\"x\"\"))\"\n" +
+		"  DUMP-NAME \"item\"\n" +
+		"\n" +
+		"ADD FIELD \"Image\" OF \"Item\" AS blob \n" +
+		"  LOB-AREA \"Lob Area\"\n" +
+		"  LOB-SIZE 10M\n" +
+		"\n" +
+		"ADD INDEX \"ItemNum\" ON \"Item\" \n" +
+		"  AREA \"Index Area\"\n" +
+		"  UNIQUE\n" +
+		"  PRIMARY\n" +
+		"  INDEX-FIELD \"ItemNum\" ASCENDING \n" +
+		"\n" +
+		".\n" +
+		"PSC\n" +
+		"cpstream=UTF-8\n" +
+		".\n" +
+		"0000000123\n"
+
+	f := mustParse(t, src)
+
+	var stmts []Statement
+	for _, n := range f.Nodes {
+		if s, ok := n.(Statement); ok {
+			stmts = append(stmts, s)
+		}
+	}
+	if len(stmts) != 4 {
+		t.Fatalf("got %d statements, want 4", len(stmts))
+	}
+
+	seq, ok := stmts[0].(*Sequence)
+	if !ok || seq.Name != "NextNum" || seq.AttrValue("INITIAL") != "10" {
+		t.Errorf("unexpected sequence: %+v", stmts[0])
+	}
+
+	tbl, ok := stmts[1].(*Table)
+	if !ok {
+		t.Fatalf("statement 2 is %T, want *Table", stmts[1])
+	}
+	if tbl.Name != "Item" || tbl.Area() != "Data Area" || tbl.AttrValue("dump-name") != "item" {
+		t.Errorf("unexpected table: name=%q area=%q", tbl.Name, tbl.Area())
+	}
+	valexp := tbl.Attr("VALEXP")
+	if want := "NOT (CAN-FIND(FIRST Customer WHERE\n   Customer.Name = \"x\"))"; valexp.Value() != want {
+		t.Errorf("VALEXP value = %q, want %q", valexp.Value(), want)
+	}
+	if len(valexp.Lines()) != 2 || valexp.Pos().Line != 6 {
+		t.Errorf("VALEXP spans %d lines at %v, want 2 lines at line 6", len(valexp.Lines()), valexp.Pos())
+	}
+	if pos := tbl.Attr("DUMP-NAME").Args[0].Pos; pos != (Pos{Line: 8, Col: 13}) {
+		t.Errorf("DUMP-NAME argument at %v, want 8:13", pos)
+	}
+
+	fld, ok := stmts[2].(*Field)
+	if !ok || fld.Name != "Image" || fld.Table != "Item" || fld.DataType != "blob" || !fld.IsLob() {
+		t.Errorf("unexpected field: %+v", stmts[2])
+	}
+	if fld.LobArea() != "Lob Area" || fld.AttrValue("LOB-SIZE") != "10M" {
+		t.Errorf("unexpected field attributes: lob-area=%q lob-size=%q", fld.LobArea(), fld.AttrValue("LOB-SIZE"))
+	}
+
+	idx, ok := stmts[3].(*Index)
+	if !ok || idx.Name != "ItemNum" || idx.Table != "Item" {
+		t.Fatalf("unexpected index: %+v", stmts[3])
+	}
+	if !idx.Unique() || !idx.Primary() || idx.Word() || idx.Area() != "Index Area" {
+		t.Errorf("unexpected index flags: unique=%v primary=%v word=%v", idx.Unique(), idx.Primary(), idx.Word())
+	}
+	if idx.Pos().Line != 14 {
+		t.Errorf("index at line %d, want 14", idx.Pos().Line)
+	}
+
+	if f.Trailer == nil {
+		t.Fatal("trailer not recognised")
+	}
+	if n, ok := f.Trailer.ByteCount(); !ok || n != 123 {
+		t.Errorf("ByteCount() = %d, %v; want 123, true", n, ok)
+	}
+}
+
+func TestAttribute_SetValue(t *testing.T) {
+	src := "ADD TABLE \"Item\"\r\n" +
+		"  AREA \"Data Area\" \r\n" +
+		"  DESCRIPTION \"one\n" +
+		"two\"\r\n" +
+		"\r\n"
+	f := mustParse(t, src)
+	tbl := f.Nodes[0].(*Table)
+
+	tbl.Attr("AREA").SetValue(`My "Area"`)
+	tbl.Attr("DESCRIPTION").SetValue("single")
+
+	want := "ADD TABLE \"Item\"\r\n" +
+		"  AREA \"My \"\"Area\"\"\" \r\n" +
+		"  DESCRIPTION \"single\"\r\n" +
+		"\r\n"
+	if got := render(t, f); got != want {
+		t.Errorf("SetValue() output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+	if got := tbl.Area(); got != `My "Area"` {
+		t.Errorf("Area() = %q after SetValue", got)
+	}
+
+	tbl.Attr("DESCRIPTION").SetValue("a\nb")
+	if got := len(tbl.Attr("DESCRIPTION").Lines()); got != 2 {
+		t.Errorf("multi-line value spans %d lines, want 2", got)
+	}
+}
+
+func TestStmt_RemoveAttrs(t *testing.T) {
+	f := mustParse(t, "ADD TABLE \"Item\"\n  AREA \"A\"\n  CAN-READ \"*\"\n  CAN-WRITE \"*\"\n\n")
+	tbl := f.Nodes[0].(*Table)
+	n := tbl.RemoveAttrs(func(a *Attribute) bool { return strings.HasPrefix(strings.ToUpper(a.Keyword), "CAN-") })
+	if n != 2 {
+		t.Errorf("RemoveAttrs() = %d, want 2", n)
+	}
+	if got, want := render(t, f), "ADD TABLE \"Item\"\n  AREA \"A\"\n\n"; got != want {
+		t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestStmt_InsertAttr(t *testing.T) {
+	f := mustParse(t, "ADD FIELD \"Img\" OF \"Item\" AS blob \r\n  POSITION 2\r\n  ORDER 10")
+	fld := f.Nodes[0].(*Field)
+
+	fld.InsertAttr(fld.AttrIndex("LOB-BYTES", "ORDER"), `  LOB-AREA "Lob Area"`)
+	fld.InsertAttr(len(fld.Attrs), "  MANDATORY")
+	fld.InsertAttr(0, "  FORMAT \"x(8)\"")
+
+	want := "ADD FIELD \"Img\" OF \"Item\" AS blob \r\n" +
+		"  FORMAT \"x(8)\"\r\n" +
+		"  POSITION 2\r\n" +
+		"  LOB-AREA \"Lob Area\"\r\n" +
+		"  ORDER 10\r\n" +
+		"  MANDATORY"
+	if got := render(t, f); got != want {
+		t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+	if fld.LobArea() != "Lob Area" {
+		t.Errorf("LobArea() = %q after insert", fld.LobArea())
+	}
+}
+
+func TestEncoder_EOL(t *testing.T) {
+	f := mustParse(t, "ADD TABLE \"Item\"\r\n  AREA \"A\"\n")
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.EOL = "\n"
+	for _, n := range f.Nodes {
+		if err := enc.Encode(n); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+	}
+	if got, want := buf.String(), "ADD TABLE \"Item\"\n  AREA \"A\"\n"; got != want {
+		t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+	if enc.Written() != int64(buf.Len()) {
+		t.Errorf("Written() = %d, want %d", enc.Written(), buf.Len())
+	}
+}
+
+func TestDecoder_Stream(t *testing.T) {
+	// A VALEXP well beyond bufio.Scanner's default 64 KiB token limit.
+	long := strings.Repeat("x", 200*1024)
+	src := "ADD TABLE \"Item\"\n  VALEXP \"" + long + "\"\n\n.\nPSC\n.\n0000000001\n"
+
+	dec := NewDecoder(strings.NewReader(src))
+	var kinds []string
+	for {
+		n, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		kinds = append(kinds, fmt.Sprintf("%T", n))
+		if tbl, ok := n.(*Table); ok && tbl.AttrValue("VALEXP") != long {
+			t.Errorf("long VALEXP value truncated to %d bytes", len(tbl.AttrValue("VALEXP")))
+		}
+	}
+	if got, want := strings.Join(kinds, ","), "*df.Table,*df.Blank,*df.Trailer"; got != want {
+		t.Errorf("node sequence = %s, want %s", got, want)
+	}
+}
+
+func TestParse_DeltaStatements(t *testing.T) {
+	src := "UPDATE TABLE \"Customer\"\n" +
+		"  DESCRIPTION \"changed\"\n" +
+		"\n" +
+		"UPDATE PRIMARY INDEX \"Name\" ON \"Customer\"\n" +
+		"\n" +
+		"DROP INDEX \"Comments\" ON \"Customer\"\n" +
+		"DROP TABLE \"Old\"\n" +
+		"\n" +
+		"RENAME FIELD \"Name\" OF \"Customer\" TO \"FullName\"\n" +
+		"RENAME INDEX \"CustNum\" TO \"CustId\" ON \"Customer\"\n" +
+		"\n" +
+		"UPDATE DATABASE \"?\"\n" +
+		"\n"
+	f := mustParse(t, src)
+
+	var got []string
+	for _, n := range f.Nodes {
+		switch n := n.(type) {
+		case *Update:
+			got = append(got, fmt.Sprintf("update %s %s/%s", n.Object(), n.Table, n.Name))
+		case *Drop:
+			got = append(got, fmt.Sprintf("drop %s %s/%s", n.Object(), n.Table, n.Name))
+		case *Rename:
+			got = append(got, fmt.Sprintf("rename %s %s/%s>%s", n.Object(), n.Table, n.Name, n.NewName))
+		case *Other:
+			got = append(got, "other "+n.Object())
+		}
+	}
+	want := []string{
+		"update TABLE /Customer",
+		"update INDEX Customer/Name",
+		"drop INDEX Customer/Comments",
+		"drop TABLE /Old",
+		"rename FIELD Customer/Name>FullName",
+		"rename INDEX Customer/CustNum>CustId",
+		"other DATABASE",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := render(t, f); got != src {
+		t.Errorf("round trip mismatch\ngot:  %q\nwant: %q", got, src)
+	}
+}
@@ -0,0 +1,154 @@
+package df
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Trailer is the PSC block that ends a .df file:
+//
+//	.
+//	PSC
+//	cpstream=UTF-8
+//	.
+//	0000060748
+//
+// The lines between PSC and the closing "." are key=value settings such as
+// cpstream, bufpool, dateformat or timestamp. The final line is the byte
+// offset of the PSC line: the number of bytes in the file up to and
+// including the opening ".".
+type Trailer struct {
+	lines []*Line
+
+	// Settings lists the key=value lines in source order.
+	Settings []*Setting
+}
+
+// Setting is a single key=value line of the trailer.
+type Setting struct {
+	Key   string
+	Value string
+
+	line *Line
+}
+
+// newTrailer builds a trailer from its lines, the first of which is the
+// opening ".".
+func newTrailer(lines []*Line) *Trailer {
+	t := &Trailer{lines: lines}
+	for _, l := range lines[1:] {
+		if k, v, ok := strings.Cut(l.Text, "="); ok {
+			t.Settings = append(t.Settings, &Setting{Key: k, Value: v, line: l})
+		}
+	}
+	return t
+}
+
+// Pos returns the position of the opening "." line.
+func (t *Trailer) Pos() Pos { return Pos{Line: t.lines[0].Num, Col: 1} }
+
+// Lines returns all trailer lines, including the byte count.
+func (t *Trailer) Lines() []*Line { return t.lines }
+
+// Setting returns the value of the setting key (case-insensitive) and
+// whether it is present.
+func (t *Trailer) Setting(key string) (string, bool) {
+	for _, s := range t.Settings {
+		if strings.EqualFold(s.Key, key) {
+			return s.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set changes the value of the setting key, adding it before the closing
+// "." if it is not present yet.
+func (t *Trailer) Set(key, value string) {
+	for _, s := range t.Settings {
+		if strings.EqualFold(s.Key, key) {
+			s.Value = value
+			s.line.Text = s.Key + "=" + value
+			return
+		}
+	}
+	at := len(t.lines)
+	if i := t.closeIndex(); i >= 0 {
+		at = i
+	}
+	l := &Line{Text: key + "=" + value, EOL: t.lines[0].EOL}
+	t.lines = append(t.lines[:at], append([]*Line{l}, t.lines[at:]...)...)
+	t.Settings = append(t.Settings, &Setting{Key: key, Value: value, line: l})
+}
+
+// Codepage returns the cpstream setting, the codepage the file is written in.
+func (t *Trailer) Codepage() string {
+	v, _ := t.Setting("cpstream")
+	return v
+}
+
+// Bufpool returns the bufpool setting.
+func (t *Trailer) Bufpool() string {
+	v, _ := t.Setting("bufpool")
+	return v
+}
+
+// HasPSC reports whether the opening "." is followed by the PSC marker.
+func (t *Trailer) HasPSC() bool {
+	return len(t.lines) > 1 && t.lines[1].Text == "PSC"
+}
+
+// Closed reports whether the settings are terminated by a second "." line.
+func (t *Trailer) Closed() bool {
+	return t.closeIndex() >= 0
+}
+
+// closeIndex returns the index of the closing "." line, or -1.
+func (t *Trailer) closeIndex() int {
+	for i := 1; i < len(t.lines); i++ {
+		if t.lines[i].Text == "." {
+			return i
+		}
+	}
+	return -1
+}
+
+// ByteCount returns the byte count declared on the last line and whether
+// the trailer ends with one.
+func (t *Trailer) ByteCount() (int64, bool) {
+	last := t.lines[len(t.lines)-1]
+	if !isByteCount(last.Text) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(last.Text, 10, 64)
+	return n, err == nil
+}
+
+// SetByteCount replaces the declared byte count, adding a byte count line
+// if the trailer has none.
+func (t *Trailer) SetByteCount(n int64) {
+	text := fmt.Sprintf("%010d", n)
+	last := t.lines[len(t.lines)-1]
+	if isByteCount(last.Text) {
+		last.Text = text
+		return
+	}
+	eol := t.lines[0].EOL
+	if last.EOL == "" {
+		last.EOL = eol
+	}
+	t.lines = append(t.lines, &Line{Text: text, EOL: eol})
+}
+
+// isByteCount reports whether s is a 10-digit byte count.
+func isByteCount(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
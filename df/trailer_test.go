@@ -0,0 +1,133 @@
+package df
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTrailer_Settings(t *testing.T) {
+	f := mustParse(t, ".\nPSC\nbufpool=yes\ncpstream=ISO8859-1\ntimestamp=2024/01/01-10:00:00\n.\n0000000042\n")
+	tr := f.Trailer
+	if tr == nil {
+		t.Fatal("trailer not recognised")
+	}
+	if !tr.HasPSC() || !tr.Closed() {
+		t.Errorf("HasPSC() = %v, Closed() = %v; want both true", tr.HasPSC(), tr.Closed())
+	}
+	if tr.Codepage() != "ISO8859-1" || tr.Bufpool() != "yes" {
+		t.Errorf("Codepage() = %q, Bufpool() = %q", tr.Codepage(), tr.Bufpool())
+	}
+	if v, ok := tr.Setting("TIMESTAMP"); !ok || v != "2024/01/01-10:00:00" {
+		t.Errorf("Setting(timestamp) = %q, %v", v, ok)
+	}
+	if len(tr.Settings) != 3 {
+		t.Errorf("got %d settings, want 3", len(tr.Settings))
+	}
+
+	tr.Set("cpstream", "UTF-8")
+	tr.Set("numformat", "44,46")
+	tr.SetByteCount(7)
+	want := ".\nPSC\nbufpool=yes\ncpstream=UTF-8\ntimestamp=2024/01/01-10:00:00\nnumformat=44,46\n.\n0000000007\n"
+	if got := render(t, f); got != want {
+		t.Errorf("output mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestEncoder_EncodeTrailer(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "byte count is regenerated",
+			input: "ADD TABLE \"T\"\n\n.\nPSC\ncpstream=UTF-8\n.\n0000099999\n",
+			want:  "ADD TABLE \"T\"\n\n.\nPSC\ncpstream=UTF-8\n.\n0000000017\n",
+		},
+		{
+			name:  "trailer without byte count is kept",
+			input: "ADD TABLE \"T\"\n\n.\nPSC\n.\n",
+			want:  "ADD TABLE \"T\"\n\n.\nPSC\n.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mustParse(t, tt.input)
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			for _, n := range f.Nodes {
+				if err := enc.Encode(n); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+			}
+			if err := enc.EncodeTrailer(f.Trailer); err != nil {
+				t.Fatalf("EncodeTrailer() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output mismatch\ngot:  %q\nwant: %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestKnownCodepage(t *testing.T) {
+	for _, cp := range []string{"UTF-8", "iso8859-1", "1252", "IBM850"} {
+		if !KnownCodepage(cp) {
+			t.Errorf("KnownCodepage(%q) = false, want true", cp)
+		}
+	}
+	if KnownCodepage("EBCDIC-XYZ") || KnownCodepage(strings.Repeat("x", 3)) {
+		t.Error("unexpected known codepage")
+	}
+}
+
+func TestDetectCodepage(t *testing.T) {
+	src := "ADD TABLE \"Item\"\r\n\r\n.\r\nPSC\r\ncpstream=ISO8859-1\r\n.\r\n0000000031\r\n"
+	r := strings.NewReader(src)
+	r.Seek(4, io.SeekStart)
+	name, err := DetectCodepage(r)
+	if err != nil || name != "ISO8859-1" {
+		t.Errorf("DetectCodepage() = %q, %v; want ISO8859-1", name, err)
+	}
+	if pos, _ := r.Seek(0, io.SeekCurrent); pos != 4 {
+		t.Errorf("reader left at %d, want 4", pos)
+	}
+
+	if name, _ := DetectCodepage(strings.NewReader("ADD TABLE \"Item\"\n")); name != "" {
+		t.Errorf("DetectCodepage() without trailer = %q, want \"\"", name)
+	}
+}
+
+func TestCodepage_RoundTrip(t *testing.T) {
+	// "Größe" in ISO8859-1.
+	src := "ADD TABLE \"Gr\xf6\xdfe\"\n  AREA \"Data\"\n\n"
+	latin1, _ := Codepage("ISO8859-1")
+
+	dec := NewDecoder(strings.NewReader(src))
+	dec.Encoding = latin1
+	n, err := dec.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	tbl := n.(*Table)
+	if tbl.Name != "Größe" {
+		t.Errorf("Name = %q, want decoded %q", tbl.Name, "Größe")
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Encoding = latin1
+	if err := enc.Encode(tbl); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if want := "ADD TABLE \"Gr\xf6\xdfe\"\n  AREA \"Data\"\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	tbl.Attr("AREA").SetValue("日本")
+	if err := enc.Encode(tbl); err == nil {
+		t.Error("expected an error encoding text outside the codepage")
+	}
+}
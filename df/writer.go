@@ -0,0 +1,99 @@
+package df
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding"
+)
+
+// Encoder writes nodes to an underlying writer and counts the bytes written.
+type Encoder struct {
+	// EOL, when non-empty, replaces the terminator of every line. When empty
+	// each line keeps the terminator it was read with. A final line without
+	// terminator is written without one either way.
+	EOL string
+
+	// Encoding, when non-nil, is the character encoding to write; every
+	// line is encoded from UTF-8. Text the encoding cannot represent is an
+	// error. See Codepage.
+	Encoding encoding.Encoding
+
+	w   io.Writer
+	enc *encoding.Encoder
+	n   int64
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes all lines of n.
+func (e *Encoder) Encode(n Node) error {
+	return e.EncodeLines(n.Lines())
+}
+
+// EncodeLines writes the given lines.
+func (e *Encoder) EncodeLines(lines []*Line) error {
+	for _, l := range lines {
+		eol := l.EOL
+		if e.EOL != "" && eol != "" {
+			eol = e.EOL
+		}
+		text := l.Text
+		if e.Encoding != nil {
+			if e.enc == nil {
+				e.enc = e.Encoding.NewEncoder()
+			}
+			var err error
+			if text, err = e.enc.String(text); err != nil {
+				return fmt.Errorf("line %d: %w", l.Num, err)
+			}
+		}
+		n, err := io.WriteString(e.w, text+eol)
+		e.n += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeTrailer writes t with its byte count set to the number of bytes
+// written up to and including its opening "." line, as OpenEdge counts
+// them, so the count matches the new content. A trailer without a byte
+// count is written unchanged.
+func (e *Encoder) EncodeTrailer(t *Trailer) error {
+	if _, ok := t.ByteCount(); !ok {
+		return e.Encode(t)
+	}
+	lines := t.Lines()
+	if err := e.EncodeLines(lines[:1]); err != nil {
+		return err
+	}
+	t.SetByteCount(e.Written())
+	return e.EncodeLines(lines[1:])
+}
+
+// Written returns the number of bytes written so far.
+func (e *Encoder) Written() int64 {
+	return e.n
+}
+
+// WriteTo writes the file in its original form, apart from any changes made
+// to its nodes. It implements io.WriterTo.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	enc := NewEncoder(w)
+	for _, n := range f.Nodes {
+		if err := enc.Encode(n); err != nil {
+			return enc.Written(), err
+		}
+	}
+	if f.Trailer != nil {
+		if err := enc.Encode(f.Trailer); err != nil {
+			return enc.Written(), err
+		}
+	}
+	return enc.Written(), nil
+}
@@ -0,0 +1,182 @@
+package schemafixer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// Severity classifies a Diagnostic.
+type Severity int
+
+const (
+	// SeverityInfo reports something the tool did, such as an inserted
+	// AREA line.
+	SeverityInfo Severity = iota
+	// SeverityWarning reports input that was processed but is probably
+	// wrong, such as an AREA line outside any statement.
+	SeverityWarning
+	// SeverityError reports input OpenEdge will not load as intended, such
+	// as a truncated file.
+	SeverityError
+)
+
+// String returns the severity as printed in diagnostics.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Diagnostic is a message about a position in a .df input.
+type Diagnostic struct {
+	Severity Severity
+	File     string // name of the input, "" if unknown
+	Pos      df.Pos // zero if the diagnostic concerns the whole file
+	Msg      string
+}
+
+// String renders the diagnostic as "file:line:col: severity: message",
+// leaving out the parts that are unknown.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.File != "" {
+		b.WriteString(d.File + ":")
+	}
+	if d.Pos.Line > 0 {
+		b.WriteString(d.Pos.String() + ":")
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(d.Severity.String() + ": " + d.Msg)
+	return b.String()
+}
+
+// report hands d to opts.Report, or logs it when there is none.
+func report(opts Options, d Diagnostic) {
+	if opts.Report != nil {
+		opts.Report(d)
+		return
+	}
+	ev := opts.Logger.Info()
+	switch d.Severity {
+	case SeverityWarning:
+		ev = opts.Logger.Warn()
+	case SeverityError:
+		ev = opts.Logger.Error()
+	}
+	ev.Str("file", d.File).Stringer("pos", d.Pos).Msg(d.Msg)
+}
+
+// inputName returns the name of in for diagnostics: the file name of an
+// *os.File or anything else with a Name method, "" otherwise.
+func inputName(in io.Reader) string {
+	if n, ok := in.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+// checker reports structural problems of a .df while it is decoded:
+// unterminated strings, statements and lines that are not understood, and
+// fields and indexes of tables the file never adds.
+type checker struct {
+	file    string
+	opts    Options
+	tables  map[string]bool
+	orphans []Diagnostic
+	delta   bool
+}
+
+// newChecker returns a checker for the .df read from in.
+func newChecker(in io.Reader, opts Options) *checker {
+	return &checker{file: inputName(in), opts: opts, tables: map[string]bool{}}
+}
+
+// report reports a diagnostic at pos.
+func (c *checker) report(sev Severity, pos df.Pos, format string, args ...any) {
+	report(c.opts, Diagnostic{Severity: sev, File: c.file, Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// check inspects one decoded node.
+func (c *checker) check(n df.Node) {
+	var attrs []*df.Attribute
+	switch n := n.(type) {
+	case df.Statement:
+		attrs = append([]*df.Attribute{n.Base().Header}, n.Base().Attrs...)
+	case *df.Raw:
+		attrs = n.Attrs
+	}
+	for _, a := range attrs {
+		for _, tok := range a.Args {
+			if tok.Unterminated {
+				c.report(SeverityError, tok.Pos, "unterminated quoted string; the file may be truncated")
+			}
+		}
+	}
+
+	switch n := n.(type) {
+	case *df.Table:
+		c.tables[strings.ToLower(n.Name)] = true
+	case *df.Field:
+		c.owner(n.Pos(), "field", n.Name, n.Table)
+	case *df.Index:
+		c.owner(n.Pos(), "index", n.Name, n.Table)
+	case *df.Update, *df.Drop, *df.Rename:
+		c.delta = true
+	case *df.Other:
+		c.report(SeverityWarning, n.Pos(), "statement not understood: %s", n.Header.Text())
+	case *df.Raw:
+		stray := false
+		for _, a := range n.Attrs {
+			if a.Is("AREA") || a.Is("LOB-AREA") {
+				stray = true
+				c.report(SeverityWarning, keywordPos(a), "%s outside any statement is ignored", strings.ToUpper(a.Keyword))
+			}
+		}
+		if !stray {
+			c.report(SeverityWarning, keywordPos(n.Attrs[0]), "line outside any statement is ignored")
+		}
+	}
+}
+
+// owner notes a field or index of table, to be reported by finish if the
+// file never added the table.
+func (c *checker) owner(pos df.Pos, kind, name, table string) {
+	if c.tables[strings.ToLower(table)] {
+		return
+	}
+	c.orphans = append(c.orphans, Diagnostic{
+		Severity: SeverityWarning,
+		File:     c.file,
+		Pos:      pos,
+		Msg:      fmt.Sprintf("%s %q is added to table %q, which is not added in this file", kind, name, table),
+	})
+}
+
+// finish reports what can only be decided at the end of the input. An
+// incremental .df adds fields and indexes to existing tables, so those are
+// not reported for it.
+func (c *checker) finish() {
+	if c.delta {
+		return
+	}
+	for _, d := range c.orphans {
+		report(c.opts, d)
+	}
+}
+
+// keywordPos returns the position of the keyword of a, after indentation.
+func keywordPos(a *df.Attribute) df.Pos {
+	text := a.Lines()[0].Text
+	return df.Pos{Line: a.Pos().Line, Col: len(text) - len(strings.TrimLeft(text, " \t")) + 1}
+}
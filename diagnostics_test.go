@@ -0,0 +1,37 @@
+package schemafixer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	src := "  AREA \"Stray\"\n" +
+		"ADD TABLE \"Item\"\n" +
+		"  AREA \"Schema Area\"\n" +
+		"\n" +
+		"ADD INDEX \"CustNum\" ON \"Customer\" \n" +
+		"  AREA \"Schema Area\"\n" +
+		"\n" +
+		"ADD DATABASE \"x\"\n" +
+		"\n" +
+		"ADD FIELD \"Note\" OF \"Item\" AS character \n" +
+		"  DESCRIPTION \"cut off\n"
+
+	var got []string
+	opts := Options{Report: func(d Diagnostic) { got = append(got, d.String()) }}
+	if _, err := ParseRules(context.Background(), strings.NewReader(src), mustRules(t, testRules), opts); err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+
+	want := []string{
+		"1:3: warning: AREA outside any statement is ignored",
+		`8:1: warning: statement not understood: ADD DATABASE "x"`,
+		"11:15: error: unterminated quoted string; the file may be truncated",
+		`5:1: warning: index "CustNum" is added to table "Customer", which is not added in this file`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
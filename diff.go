@@ -0,0 +1,477 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// NotPresent is the area shown in a DiffRow for a construct that exists in
+// only one of the two schemas.
+const NotPresent = "(not present)"
+
+// areaRecord holds the area assignment for a single .df construct.
+type areaRecord struct {
+	kind  Kind
+	name  string // e.g. "Customer", "Customer.CustNum", "Item.ItemImage"
+	key   string // lowercase unique key for matching
+	area  string
+	class string // see IndexInfo.Class and FieldInfo.Class
+	id    string // key of the construct in the source schema, kept across renames
+}
+
+// areaSet is the state of a schema's areas while its statements are played
+// in order: ADD statements add records, DROP and RENAME statements of an
+// incremental .df remove and rename them.
+type areaSet struct {
+	records []areaRecord
+}
+
+// areaOp is one change to an areaSet, recorded while reading a .df.
+type areaOp func(*areaSet)
+
+// add adds r, replacing a record with the same key.
+func (s *areaSet) add(r areaRecord) {
+	r.id = r.key
+	for i := range s.records {
+		if s.records[i].key == r.key {
+			s.records[i] = r
+			return
+		}
+	}
+	s.records = append(s.records, r)
+}
+
+// drop removes the record with key and, for a table, its indexes and LOBs.
+func (s *areaSet) drop(key string) {
+	kept := s.records[:0]
+	for _, r := range s.records {
+		if r.key != key && !ownedBy(r, key) {
+			kept = append(kept, r)
+		}
+	}
+	s.records = kept
+}
+
+// rename changes the record with key to the new name and key. Renaming a
+// table also renames the table part of its indexes and LOBs.
+func (s *areaSet) rename(key, name, newKey string) {
+	for i := range s.records {
+		r := &s.records[i]
+		switch {
+		case r.key == key:
+			r.name, r.key = name, newKey
+		case ownedBy(*r, key):
+			_, sub, _ := strings.Cut(r.name, ".")
+			_, subKey, _ := strings.Cut(r.key, ".")
+			r.name = name + "." + sub
+			r.key = strings.ToLower(r.kind.String()) + ":" + strings.TrimPrefix(newKey, "table:") + "." + subKey
+		}
+	}
+}
+
+// ownedBy reports whether r is an index or LOB of the table with key.
+func ownedBy(r areaRecord, key string) bool {
+	table, ok := strings.CutPrefix(key, "table:")
+	if !ok || r.kind == KindTable {
+		return false
+	}
+	_, rest, _ := strings.Cut(r.key, ":")
+	return strings.HasPrefix(rest, table+".")
+}
+
+// areaKey returns the record key of a construct of kind, with table empty
+// for tables.
+func areaKey(kind Kind, table, name string) string {
+	if kind == KindTable {
+		return "table:" + strings.ToLower(name)
+	}
+	return strings.ToLower(kind.String()) + ":" + strings.ToLower(table) + "." + strings.ToLower(name)
+}
+
+// areaName returns the display name of a construct, see areaRecord.name.
+func areaName(kind Kind, table, name string) string {
+	if kind == KindTable {
+		return name
+	}
+	return table + "." + name
+}
+
+// DiffRow is one area difference between two schemas.
+type DiffRow struct {
+	Kind       Kind
+	Name       string // e.g. "Customer", "Customer.CustNum", "Item.ItemImage"
+	SourceArea string // NotPresent if the construct is only in the target
+	TargetArea string // NotPresent if the construct is only in the source
+	Class      string // see IndexInfo.Class and FieldInfo.Class
+}
+
+// Construct returns the kind of the row with its class, e.g. "INDEX (word)".
+func (r DiffRow) Construct() string {
+	if r.Class == "" {
+		return r.Kind.String()
+	}
+	return r.Kind.String() + " (" + r.Class + ")"
+}
+
+// DiffResult holds the area differences found by Diff.
+type DiffResult struct {
+	// Rows lists the differences in source order, followed by the
+	// constructs that only exist in the target.
+	Rows []DiffRow
+
+	sourceMap map[string]*areaRecord
+	targetMap map[string]*areaRecord
+}
+
+// Diff compares the areas of all tables, indexes and LOB fields of the
+// source and target .df files.
+//
+// When the target is an incremental .df, recognised by its UPDATE, DROP or
+// RENAME statements, it is played on top of the source: dropped constructs
+// are reported as not present in the target, and renamed constructs are
+// compared with their source counterparts under their new names.
+func Diff(ctx context.Context, source, target io.Reader, opts Options) (*DiffResult, error) {
+	log := opts.Logger
+
+	sourceOps, _, err := extractAreas(ctx, source, opts)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	targetOps, delta, err := extractAreas(ctx, target, opts)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	src := &areaSet{}
+	for _, op := range sourceOps {
+		op(src)
+	}
+	for i := range src.records {
+		src.records[i].id = src.records[i].key
+	}
+	tgt := &areaSet{}
+	if delta {
+		log.Debug().Msg("target is an incremental df, applying it to the source")
+		tgt.records = append(tgt.records, src.records...)
+	}
+	for _, op := range targetOps {
+		op(tgt)
+	}
+	if !delta {
+		for i := range tgt.records {
+			tgt.records[i].id = tgt.records[i].key
+		}
+	}
+	sourceRecords, targetRecords := src.records, tgt.records
+	log.Debug().Int("sourceConstructs", len(sourceRecords)).Int("targetConstructs", len(targetRecords)).Msg("areas extracted")
+
+	// Build lookup maps keyed by the lowercase key.
+	res := &DiffResult{
+		sourceMap: make(map[string]*areaRecord, len(sourceRecords)),
+		targetMap: make(map[string]*areaRecord, len(targetRecords)),
+	}
+	for i := range sourceRecords {
+		res.sourceMap[sourceRecords[i].key] = &sourceRecords[i]
+	}
+	targetByID := make(map[string]*areaRecord, len(targetRecords))
+	for i := range targetRecords {
+		res.targetMap[targetRecords[i].key] = &targetRecords[i]
+		targetByID[targetRecords[i].id] = &targetRecords[i]
+	}
+
+	// Walk source records — compare against target. Records are matched by
+	// id, which follows renames in an incremental target.
+	for _, rec := range sourceRecords {
+		tgt, ok := targetByID[rec.id]
+		if !ok {
+			// Present in source only.
+			res.Rows = append(res.Rows, DiffRow{rec.kind, rec.name, rec.area, NotPresent, rec.class})
+			continue
+		}
+		if tgt.name != rec.name {
+			log.Debug().Str("from", rec.name).Str("to", tgt.name).Msg("renamed")
+		}
+		if !strings.EqualFold(rec.area, tgt.area) {
+			res.Rows = append(res.Rows, DiffRow{rec.kind, tgt.name, rec.area, tgt.area, tgt.class})
+		}
+	}
+
+	// Walk target records — add those not in source.
+	for _, rec := range targetRecords {
+		if _, ok := res.sourceMap[rec.id]; !ok {
+			res.Rows = append(res.Rows, DiffRow{rec.kind, rec.name, NotPresent, rec.area, rec.class})
+		}
+	}
+
+	log.Debug().Int("differences", len(res.Rows)).Msg("diff complete")
+	return res, nil
+}
+
+// WriteTable renders the differences as a fixed-column table.
+func (d *DiffResult) WriteTable(w io.Writer) error {
+	// Determine column widths dynamically.
+	const (
+		hConstruct = "CONSTRUCT"
+		hName      = "NAME"
+		hSource    = "SOURCE AREA"
+		hTarget    = "TARGET AREA"
+	)
+
+	wConstruct := len(hConstruct)
+	wName := len(hName)
+	wSource := len(hSource)
+
+	for _, r := range d.Rows {
+		if len(r.Construct()) > wConstruct {
+			wConstruct = len(r.Construct())
+		}
+		if len(r.Name) > wName {
+			wName = len(r.Name)
+		}
+		if len(r.SourceArea) > wSource {
+			wSource = len(r.SourceArea)
+		}
+	}
+
+	// Add padding between columns.
+	wConstruct += 2
+	wName += 2
+	wSource += 2
+
+	var err error
+	fmtRow := func(c, n, s, t string) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%-*s%-*s%-*s%s\n", wConstruct, c, wName, n, wSource, s, t)
+		}
+	}
+
+	fmtRow(hConstruct, hName, hSource, hTarget)
+	fmtRow(strings.Repeat("-", wConstruct-2), strings.Repeat("-", wName-2), strings.Repeat("-", wSource-2), strings.Repeat("-", len(hTarget)))
+
+	for _, r := range d.Rows {
+		fmtRow(r.Construct(), r.Name, r.SourceArea, r.TargetArea)
+	}
+	return err
+}
+
+// WriteTablemove renders the differences as proutil tablemove commands for
+// the database db, one per table whose table, index or LOB area changed.
+func (d *DiffResult) WriteTablemove(w io.Writer, db string) error {
+	return printProutilCommands(w, d.Rows, d.sourceMap, d.targetMap, db)
+}
+
+// extractAreas reads a .df and returns the changes its statements make to
+// the areas, in source order. delta reports whether the .df is incremental.
+func extractAreas(ctx context.Context, in io.Reader, opts Options) (ops []areaOp, delta bool, err error) {
+	_, cp, err := codepage(in, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	add := func(kind Kind, table, name, area, class string) {
+		r := areaRecord{kind: kind, name: areaName(kind, table, name), key: areaKey(kind, table, name), area: area, class: class}
+		ops = append(ops, func(s *areaSet) { s.add(r) })
+	}
+
+	err = decode(ctx, in, cp, newChecker(in, opts), func(n df.Node) error {
+		switch n := n.(type) {
+		case *df.Table:
+			if a := n.Attr("AREA"); a != nil {
+				add(KindTable, "", n.Name, a.Value(), "")
+			}
+
+		case *df.Index:
+			if a := n.Attr("AREA"); a != nil {
+				add(KindIndex, n.Table, n.Name, a.Value(), indexInfo(n).Class())
+			}
+
+		case *df.Field:
+			if a := n.Attr("LOB-AREA"); a != nil {
+				add(KindLob, n.Table, n.Name, a.Value(), fieldInfo(n).Class())
+			}
+
+		case *df.Update:
+			delta = true
+
+		case *df.Drop:
+			delta = true
+			for _, kind := range deltaKinds(n.Object()) {
+				key := areaKey(kind, n.Table, n.Name)
+				ops = append(ops, func(s *areaSet) { s.drop(key) })
+			}
+
+		case *df.Rename:
+			delta = true
+			for _, kind := range deltaKinds(n.Object()) {
+				key := areaKey(kind, n.Table, n.Name)
+				name, newKey := areaName(kind, n.Table, n.NewName), areaKey(kind, n.Table, n.NewName)
+				ops = append(ops, func(s *areaSet) { s.rename(key, name, newKey) })
+			}
+		}
+		return nil
+	})
+
+	return ops, delta, err
+}
+
+// deltaKinds returns the kinds of area records a DROP or RENAME of object
+// may affect. A field is only a LOB if it has a LOB-AREA, so dropping or
+// renaming one that has none is a no-op.
+func deltaKinds(object string) []Kind {
+	switch object {
+	case "TABLE":
+		return []Kind{KindTable}
+	case "INDEX":
+		return []Kind{KindIndex}
+	case "FIELD":
+		return []Kind{KindLob}
+	}
+	return nil
+}
+
+// quoteIfNeeded wraps an area name in double quotes if it contains spaces.
+func quoteIfNeeded(area string) string {
+	if strings.Contains(area, " ") {
+		return fmt.Sprintf("\"%s\"", area)
+	}
+	return area
+}
+
+// printProutilCommands generates proutil tablemove commands for tables with area changes.
+func printProutilCommands(w io.Writer, rows []DiffRow, sourceMap, targetMap map[string]*areaRecord, tablemoveDB string) error {
+	// Group changes by table.
+	type tableChange struct {
+		tableName string
+		tableArea string
+		indexArea string
+		lobArea   string
+		hasTable  bool
+		hasIndex  bool
+		hasLob    bool
+	}
+
+	tableChanges := make(map[string]*tableChange)
+	// tableOrder tracks each table's first-encounter order in rows, since Go
+	// map iteration order is randomized and must not leak into output.
+	var tableOrder []string
+
+	for _, row := range rows {
+		// Skip rows where target is missing.
+		if row.TargetArea == NotPresent {
+			continue
+		}
+
+		// Extract table name.
+		var tableName string
+		switch row.Kind {
+		case KindTable:
+			tableName = row.Name
+		case KindIndex, KindLob:
+			// Name format is "TableName.FieldName" or "TableName.IndexName"
+			parts := strings.SplitN(row.Name, ".", 2)
+			if len(parts) < 2 {
+				continue
+			}
+			tableName = parts[0]
+		}
+
+		// Initialize table change if not exists.
+		if tableChanges[tableName] == nil {
+			tableChanges[tableName] = &tableChange{tableName: tableName}
+			tableOrder = append(tableOrder, tableName)
+		}
+
+		tc := tableChanges[tableName]
+		switch row.Kind {
+		case KindTable:
+			tc.tableArea = row.TargetArea
+			tc.hasTable = true
+		case KindIndex:
+			tc.indexArea = row.TargetArea
+			tc.hasIndex = true
+		case KindLob:
+			tc.lobArea = row.TargetArea
+			tc.hasLob = true
+		}
+	}
+
+	// Generate proutil commands in first-encounter order (see tableOrder).
+	for _, tableName := range tableOrder {
+		tc := tableChanges[tableName]
+		if !tc.hasTable && !tc.hasIndex && !tc.hasLob {
+			continue
+		}
+
+		// Get table area from target if we have a table change, otherwise from source/target maps.
+		tableArea := tc.tableArea
+		if tableArea == "" {
+			// Look up the table area from source or target.
+			key := "table:" + strings.ToLower(tc.tableName)
+			if rec, ok := targetMap[key]; ok {
+				tableArea = rec.area
+			} else if rec, ok := sourceMap[key]; ok {
+				tableArea = rec.area
+			}
+		}
+
+		// Get index area.
+		indexArea := tc.indexArea
+		if indexArea == "" && tc.hasIndex {
+			// Find any index for this table in target.
+			for key, rec := range targetMap {
+				if strings.HasPrefix(key, "index:"+strings.ToLower(tc.tableName)+".") {
+					indexArea = rec.area
+					break
+				}
+			}
+		}
+		if indexArea == "" {
+			// Fallback to source.
+			for key, rec := range sourceMap {
+				if strings.HasPrefix(key, "index:"+strings.ToLower(tc.tableName)+".") {
+					indexArea = rec.area
+					break
+				}
+			}
+		}
+
+		// Get LOB area if needed.
+		lobArea := tc.lobArea
+		if lobArea == "" && tc.hasLob {
+			// Find any LOB for this table in target.
+			for key, rec := range targetMap {
+				if strings.HasPrefix(key, "lob:"+strings.ToLower(tc.tableName)+".") {
+					lobArea = rec.area
+					break
+				}
+			}
+		}
+		if lobArea == "" && tc.hasLob {
+			// Fallback to source.
+			for key, rec := range sourceMap {
+				if strings.HasPrefix(key, "lob:"+strings.ToLower(tc.tableName)+".") {
+					lobArea = rec.area
+					break
+				}
+			}
+		}
+
+		// Build the proutil command.
+		cmd := fmt.Sprintf("proutil %s -C tablemove %s %s", tablemoveDB, tc.tableName, quoteIfNeeded(tableArea))
+		if indexArea != "" {
+			cmd += " " + quoteIfNeeded(indexArea)
+		}
+		if lobArea != "" && tc.hasLob {
+			cmd += " " + quoteIfNeeded(lobArea)
+		}
+
+		if _, err := fmt.Fprintln(w, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package schemafixer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestDiff(t *testing.T) {
+	target := strings.Replace(testDF, "Schema Area", "data", 1)
+	target = strings.Replace(target, "ADD FIELD \"ItemImage\" OF \"Item\" AS blob \n  LOB-AREA \"Schema Area\"\n\n", "", 1)
+
+	res, err := Diff(context.Background(), strings.NewReader(testDF), strings.NewReader(target), Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	want := []DiffRow{
+		{KindTable, "Customer", "Schema Area", "data", ""},
+		{KindLob, "Item.ItemImage", "Schema Area", NotPresent, "blob"},
+	}
+	if len(res.Rows) != len(want) {
+		t.Fatalf("got rows %+v, want %+v", res.Rows, want)
+	}
+	for i := range want {
+		if res.Rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, res.Rows[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := res.WriteTablemove(&buf, "sports"); err != nil {
+		t.Fatalf("WriteTablemove() error = %v", err)
+	}
+	if got, want := buf.String(), "proutil sports -C tablemove Customer data \"Schema Area\"\n"; got != want {
+		t.Errorf("WriteTablemove() = %q, want %q", got, want)
+	}
+}
+
+func TestDiff_Delta(t *testing.T) {
+	delta := "RENAME TABLE \"Customer\" TO \"Client\"\n" +
+		"\n" +
+		"DROP FIELD \"ItemImage\" OF \"Item\"\n" +
+		"\n" +
+		"UPDATE TABLE \"Item\"\n" +
+		"  DESCRIPTION \"changed\"\n" +
+		"\n" +
+		"ADD INDEX \"Name\" ON \"Client\" \n" +
+		"  AREA \"Index Area\"\n" +
+		"\n" +
+		"UPDATE DATABASE \"?\"\n" +
+		"\n"
+
+	var logs bytes.Buffer
+	opts := Options{Logger: zerolog.New(&logs)}
+	res, err := Diff(context.Background(), strings.NewReader(testDF), strings.NewReader(delta), opts)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	// Customer and its index are renamed, not dropped: no difference.
+	want := []DiffRow{
+		{KindLob, "Item.ItemImage", "Schema Area", NotPresent, "blob"},
+		{KindIndex, "Client.Name", NotPresent, "Index Area", ""},
+	}
+	if len(res.Rows) != len(want) {
+		t.Fatalf("got rows %+v, want %+v", res.Rows, want)
+	}
+	for i := range want {
+		if res.Rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, res.Rows[i], want[i])
+		}
+	}
+	if !strings.Contains(logs.String(), "statement not understood") || !strings.Contains(logs.String(), `"pos":"11:1"`) {
+		t.Errorf("expected a warning for UPDATE DATABASE, got %q", logs.String())
+	}
+}
@@ -0,0 +1,236 @@
+package schemafixer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Environment overrides parts of the shared rules for one environment, such
+// as prod. See SchemaFixerRules.Environment for how it is merged.
+type Environment struct {
+	Defaults AreaDefaults `yaml:"defaults,omitempty"`
+	Tables   []TableRule  `yaml:"tables,omitempty"`
+	Rules    []AreaRule   `yaml:"rules,omitempty"`
+	Groups   []Group      `yaml:"groups,omitempty"`
+
+	AreaMap map[string]string `yaml:"areaMap,omitempty"`
+	Areas   []string          `yaml:"areas,omitempty"`
+
+	pos map[string]source // "defaults.<kind>" and "areaMap.<area>"
+}
+
+// Environment returns the rules for the environment name: the shared rules
+// with the overlay of environments[name] merged on top. The result has no
+// environments of its own. An empty name returns r itself.
+//
+// The overlay is merged field by field:
+//   - defaults: every non-empty area replaces the shared one.
+//   - tables: an entry with the same name as a shared entry (compared
+//     case-insensitively) is merged into it: a non-empty area, indexArea or
+//     lobArea replaces the shared one, and indexes and lobs are merged key
+//     by key, the overlay's value winning. An empty value removes the shared
+//     key, so the construct falls back to indexArea or lobArea, the
+//     conditional rules and the defaults. Entries with a new name are added
+//     after the shared ones.
+//   - rules: the overlay's conditional rules are evaluated before the shared
+//     ones.
+//   - groups: a group with the same name as a shared group is merged into
+//     it: non-empty areas, a non-empty tables list and a non-zero priority
+//     replace the shared ones. Other groups are added.
+//   - areaMap: merged key by key like indexes and lobs.
+//   - areas: a non-empty catalog replaces the shared one.
+func (r *SchemaFixerRules) Environment(name string) (*SchemaFixerRules, error) {
+	if name == "" {
+		return r, nil
+	}
+	env, ok := r.Environments[name]
+	if !ok {
+		if len(r.Environments) == 0 {
+			return nil, fmt.Errorf("unknown environment %q: the rules define no environments", name)
+		}
+		return nil, fmt.Errorf("unknown environment %q (defined: %s)", name, strings.Join(slices.Sorted(maps.Keys(r.Environments)), ", "))
+	}
+
+	layer := merge(r.layer(), env)
+	return &SchemaFixerRules{
+		Version:  r.Version,
+		Defaults: layer.Defaults,
+		Tables:   layer.Tables,
+		Rules:    layer.Rules,
+		Groups:   layer.Groups,
+		AreaMap:  layer.AreaMap,
+		MapOrder: r.MapOrder,
+		Areas:    layer.Areas,
+		Vars:     r.Vars,
+		pos:      layer.pos,
+	}, nil
+}
+
+// layer returns the shared defaults, tables and rules of r.
+func (r *SchemaFixerRules) layer() Environment {
+	return Environment{Defaults: r.Defaults, Tables: r.Tables, Rules: r.Rules, Groups: r.Groups, AreaMap: r.AreaMap, Areas: r.Areas, pos: r.pos}
+}
+
+// merge returns top merged over base as described for Environment. Neither
+// is modified.
+func merge(base, top Environment) Environment {
+	out := Environment{
+		Defaults: base.Defaults,
+		Tables:   make([]TableRule, 0, len(base.Tables)+len(top.Tables)),
+		Rules:    append(slices.Clone(top.Rules), base.Rules...),
+		Groups:   slices.Clone(base.Groups),
+		AreaMap:  mergeAreas(maps.Clone(base.AreaMap), top.AreaMap),
+		Areas:    base.Areas,
+		pos:      base.pos,
+	}
+	if len(top.Areas) > 0 {
+		out.Areas = top.Areas
+	}
+	for k := range top.AreaMap {
+		out.setPos("areaMap."+k, top.pos["areaMap."+k])
+	}
+	for _, d := range defaultFields(&out.Defaults, &top.Defaults) {
+		if *d.src != "" {
+			*d.dst = *d.src
+			out.setPos(d.key, top.pos[d.key])
+		}
+	}
+
+	for _, t := range base.Tables {
+		t.Indexes = maps.Clone(t.Indexes)
+		t.Lobs = maps.Clone(t.Lobs)
+		out.Tables = append(out.Tables, t)
+	}
+	for _, o := range top.Tables {
+		i := slices.IndexFunc(out.Tables, func(t TableRule) bool { return strings.EqualFold(t.Name, o.Name) })
+		if i < 0 {
+			o.Indexes = mergeAreas(nil, o.Indexes)
+			o.Lobs = mergeAreas(nil, o.Lobs)
+			out.Tables = append(out.Tables, o)
+			continue
+		}
+		t := &out.Tables[i]
+		if o.Area != "" {
+			t.Area = o.Area
+		}
+		if o.IndexArea != "" {
+			t.IndexArea = o.IndexArea
+		}
+		if o.LobArea != "" {
+			t.LobArea = o.LobArea
+		}
+		t.Indexes = mergeAreas(t.Indexes, o.Indexes)
+		t.Lobs = mergeAreas(t.Lobs, o.Lobs)
+		t.pos = maps.Clone(t.pos)
+		for k, s := range o.pos {
+			if t.pos == nil {
+				t.pos = map[string]source{}
+			}
+			t.pos[k] = s
+		}
+	}
+	for _, o := range top.Groups {
+		i := slices.IndexFunc(out.Groups, func(g Group) bool { return strings.EqualFold(g.Name, o.Name) })
+		if i < 0 {
+			out.Groups = append(out.Groups, o)
+			continue
+		}
+		g := &out.Groups[i]
+		g.pos = maps.Clone(g.pos)
+		for _, f := range []struct {
+			key      string
+			dst, src *string
+		}{
+			{"area", &g.Area, &o.Area},
+			{"indexArea", &g.IndexArea, &o.IndexArea},
+			{"lobArea", &g.LobArea, &o.LobArea},
+		} {
+			if *f.src != "" {
+				*f.dst = *f.src
+				if g.pos == nil {
+					g.pos = map[string]source{}
+				}
+				g.pos[f.key] = o.pos[f.key]
+			}
+		}
+		if len(o.Tables) > 0 {
+			g.Tables = o.Tables
+		}
+		if o.Priority != 0 {
+			g.Priority = o.Priority
+		}
+	}
+	return out
+}
+
+// defaultField is one area of two AreaDefaults, with its key in the rules
+// file.
+type defaultField struct {
+	key      string
+	dst, src *string
+}
+
+// defaultFields pairs the areas of dst and src.
+func defaultFields(dst, src *AreaDefaults) []defaultField {
+	return []defaultField{
+		{"defaults.table", &dst.Table, &src.Table},
+		{"defaults.index", &dst.Index, &src.Index},
+		{"defaults.lob", &dst.Lob, &src.Lob},
+		{"defaults.index.word", &dst.IndexWord, &src.IndexWord},
+		{"defaults.index.primary", &dst.IndexPrimary, &src.IndexPrimary},
+		{"defaults.index.unique", &dst.IndexUnique, &src.IndexUnique},
+		{"defaults.lob.blob", &dst.LobBlob, &src.LobBlob},
+		{"defaults.lob.clob", &dst.LobClob, &src.LobClob},
+	}
+}
+
+// mergeAreas merges the index or LOB areas of overlay into base, which it
+// may modify. Keys are compared case-insensitively; an empty value removes
+// the key.
+func mergeAreas(base, overlay map[string]string) map[string]string {
+	for k, v := range overlay {
+		for bk := range base {
+			if strings.EqualFold(bk, k) {
+				delete(base, bk)
+			}
+		}
+		if v == "" {
+			continue
+		}
+		if base == nil {
+			base = map[string]string{}
+		}
+		base[k] = v
+	}
+	return base
+}
+
+// validateEnvironments checks the patterns and conditions of every
+// environment overlay.
+func (r *SchemaFixerRules) validateEnvironments() error {
+	for _, name := range slices.Sorted(maps.Keys(r.Environments)) {
+		env := r.Environments[name]
+		overlay := &SchemaFixerRules{Defaults: env.Defaults, Tables: env.Tables, Rules: env.Rules, Groups: env.Groups}
+		if err := overlay.validatePatterns(); err != nil {
+			return fmt.Errorf("environments.%s: %w", name, err)
+		}
+		if err := overlay.validateRules(); err != nil {
+			return fmt.Errorf("environments.%s: %w", name, err)
+		}
+		if err := overlay.validateGroups(); err != nil {
+			return fmt.Errorf("environments.%s: %w", name, err)
+		}
+		if err := overlay.validateTemplates(); err != nil {
+			return fmt.Errorf("environments.%s: %w", name, err)
+		}
+		if err := validateMapKeys(env.AreaMap); err != nil {
+			return fmt.Errorf("environments.%s: %w", name, err)
+		}
+		if err := validateAreas(env.Areas); err != nil {
+			return fmt.Errorf("environments.%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package schemafixer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRules_Environment(t *testing.T) {
+	rf, err := ReadRules(strings.NewReader(`schemafixer:
+  version: 1.0
+  defaults:
+    table: DataArea
+    index: IndexArea
+    lob: LobArea
+  tables:
+    - name: customer
+      area: CustData
+      indexes:
+        custnum: CustIdx
+        name: NameIdx
+  rules:
+    - when: index.word
+      area: WordIdx
+  environments:
+    prod:
+      defaults:
+        index: ProdIdx
+      tables:
+        - name: Customer
+          indexes:
+            CustNum: ProdCustIdx
+            name: ""
+        - name: order
+          area: ProdOrders
+      rules:
+        - when: index.word && index.unique
+          area: ProdUniqueWord
+`))
+	if err != nil {
+		t.Fatalf("ReadRules() error = %v", err)
+	}
+	base := &rf.SchemaFixer
+
+	prod, err := base.Environment("prod")
+	if err != nil {
+		t.Fatalf("Environment() error = %v", err)
+	}
+	area := areaOf(t)
+	checks := []struct{ got, want string }{
+		{prod.Defaults.Table, "DataArea"},
+		{prod.Defaults.Index, "ProdIdx"},
+		{prod.TableArea("customer"), "CustData"},
+		{prod.TableArea("order"), "ProdOrders"},
+		{prod.IndexArea("customer", "custnum"), "ProdCustIdx"},
+		{prod.IndexArea("customer", "name"), "ProdIdx"},
+		{area(prod.IndexAreaFor(&TableInfo{Name: "x"}, &IndexInfo{Table: "x", Name: "w", Word: true, Unique: true})), "ProdUniqueWord"},
+		{area(prod.IndexAreaFor(&TableInfo{Name: "x"}, &IndexInfo{Table: "x", Name: "w", Word: true})), "WordIdx"},
+		// The shared rules are unchanged.
+		{base.IndexArea("customer", "custnum"), "CustIdx"},
+		{base.IndexArea("customer", "name"), "NameIdx"},
+	}
+	for i, c := range checks {
+		if c.got != c.want {
+			t.Errorf("check %d: got %q, want %q", i, c.got, c.want)
+		}
+	}
+	if len(prod.Environments) != 0 {
+		t.Errorf("resolved rules still have environments")
+	}
+
+	if _, err := base.Environment("qa"); err == nil || !strings.Contains(err.Error(), `unknown environment "qa" (defined: prod)`) {
+		t.Errorf("Environment(qa) error = %v", err)
+	}
+	if r, err := base.Environment(""); err != nil || r != base {
+		t.Errorf("Environment(\"\") = %v, %v, want the shared rules", r, err)
+	}
+}
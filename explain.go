@@ -0,0 +1,183 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ExplainResult lists how the areas of the constructs Explain was asked
+// about are resolved.
+type ExplainResult struct {
+	// Explanations has one entry for every table, index or LOB field of the
+	// .df with the name asked about, in source order. An index and a LOB
+	// field of the same name are both explained.
+	Explanations []Explanation
+}
+
+// Explanation tells how the area of one table, index or LOB field is
+// resolved.
+type Explanation struct {
+	Kind    Kind
+	Name    string // e.g. "Customer", "Customer.CustNum"
+	File    string // the .df, "" if unnamed
+	Line    int    // of the ADD statement
+	Current string // the area it is in, "Schema Area" without an AREA or LOB-AREA line
+	Area    string // the area Apply gives it
+
+	// Steps lists the rules considered, in order of precedence: the rules
+	// that were skipped, with the reason, then the one that decided. An
+	// area map entry that renamed the area comes first with mapOrder first
+	// and last with mapOrder last.
+	Steps []ExplainStep
+}
+
+// ExplainStep is one rule considered for a construct.
+type ExplainStep struct {
+	Rule    string // where in the rules, e.g. "tables[0] (customer) indexes.custnum", "rules[1]" or "defaults.index"
+	File    string
+	Line    int    // 0 if unknown
+	Area    string // as the rule writes it, "" if it sets none
+	Skipped string // why the rule did not decide, "" if it did
+}
+
+// Decided returns the step that decided the area, or nil.
+func (e *Explanation) Decided() *ExplainStep {
+	for i := range e.Steps {
+		if e.Steps[i].Skipped == "" && !strings.HasPrefix(e.Steps[i].Rule, "areaMap.") {
+			return &e.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Explain reads a .df from in and tells how Apply, given rules and opts,
+// resolves the area of the construct called name: a table, e.g.
+// "Customer", or an index or LOB field, e.g. "Customer.CustNum". Names are
+// compared case-insensitively. It is an error if the .df has no construct
+// of that name. Of the diagnostics Apply would report, only errors and those
+// about the constructs explained are passed to opts.Report.
+func Explain(ctx context.Context, in io.Reader, rules *SchemaFixerRules, name string, opts Options) (*ExplainResult, error) {
+	table, field, _ := strings.Cut(name, ".")
+	if table == "" {
+		return nil, fmt.Errorf("explain: no name given")
+	}
+	x := &explainer{table: table, name: field, file: inputName(in), seen: map[string]string{}}
+	// Only errors and the diagnostics about the constructs explained are
+	// passed on.
+	outer := opts
+	opts.Report = func(d Diagnostic) {
+		if d.Severity == SeverityError || slices.ContainsFunc(x.res.Explanations, func(e Explanation) bool { return e.Line == d.Pos.Line }) {
+			report(outer, d)
+		}
+	}
+	if _, err := apply(ctx, in, rules, io.Discard, opts, x); err != nil {
+		return nil, err
+	}
+	if len(x.res.Explanations) == 0 {
+		names := slices.Sorted(maps.Values(x.seen))
+		return nil, fmt.Errorf("the .df has no table, index or LOB field %q%s", name, suggest(name, names))
+	}
+	return &x.res, nil
+}
+
+// explainer collects the Explanations of Explain.
+type explainer struct {
+	table, name string // name is "" for a table
+	file        string
+	res         ExplainResult
+	seen        map[string]string // lowercase → name of every construct, for suggestions
+}
+
+// trace returns the Explanation to record the resolution of the construct
+// kind name of table in, or nil if it is not the one asked about. x may be
+// nil.
+func (x *explainer) trace(kind Kind, table, name string, line int, current string) *Explanation {
+	if x == nil {
+		return nil
+	}
+	full := table
+	if kind != KindTable {
+		full += "." + name
+	}
+	x.seen[strings.ToLower(full)] = full
+	if !strings.EqualFold(table, x.table) || !strings.EqualFold(name, x.name) {
+		return nil
+	}
+	x.res.Explanations = append(x.res.Explanations, Explanation{Kind: kind, Name: full, File: x.file, Line: line, Current: current})
+	return &x.res.Explanations[len(x.res.Explanations)-1]
+}
+
+// The methods below record the steps of an Explanation; on a nil
+// *Explanation they do nothing, so the lookups can call them unconditionally.
+
+// skip records that rule, at at and setting area, did not decide, for the
+// reason given by format and args.
+func (e *Explanation) skip(rule string, at source, area string, format string, args ...any) {
+	if e == nil {
+		return
+	}
+	e.Steps = append(e.Steps, ExplainStep{Rule: rule, File: at.file, Line: at.line, Area: area, Skipped: fmt.Sprintf(format, args...)})
+}
+
+// decide records that rule decided d, and returns d.
+func (e *Explanation) decide(rule string, d decision) decision {
+	if e != nil {
+		e.Steps = append(e.Steps, ExplainStep{Rule: rule, File: d.at.file, Line: d.at.line, Area: d.area})
+	}
+	return d
+}
+
+// mapped records that the area map renamed area to the area of d.
+func (e *Explanation) mapped(area string, d decision) {
+	if e != nil {
+		e.Steps = append(e.Steps, ExplainStep{Rule: "areaMap." + area, File: d.at.file, Line: d.at.line, Area: d.area})
+	}
+}
+
+// shadowed records the keys of section ("indexes" or "lobs") of the table
+// rule m that match name as well as key, but less well.
+func (e *Explanation) shadowed(m tableMatch, section, key, name string) {
+	if e == nil {
+		return
+	}
+	entries := m.rule.Indexes
+	if section == "lobs" {
+		entries = m.rule.Lobs
+	}
+	for _, k := range slices.Sorted(maps.Keys(entries)) {
+		if k != key && match(k, name) != NoMatch {
+			e.skip(m.path()+" "+section+"."+k, m.rule.at(section+"."+k), entries[k], "%q matches better", key)
+		}
+	}
+}
+
+// otherGroups records the groups of r that have tableName as a member but
+// are not g, the group it belongs to.
+func (e *Explanation) otherGroups(r *SchemaFixerRules, tableName string, g *Group) {
+	if e == nil {
+		return
+	}
+	for i := range r.Groups {
+		o := &r.Groups[i]
+		if o == g || !slices.ContainsFunc(o.Tables, func(p string) bool { return match(p, tableName) != NoMatch }) {
+			continue
+		}
+		e.skip(fmt.Sprintf("groups[%d] (%s)", i, o.Name), o.src, "", "the table belongs to group %q, of priority %d", g.Name, g.Priority)
+	}
+}
+
+// kindPlural returns what the constructs of kind are called in
+// explanations, e.g. "LOB fields".
+func kindPlural(kind Kind) string {
+	switch kind {
+	case KindTable:
+		return "tables"
+	case KindIndex:
+		return "indexes"
+	}
+	return "LOB fields"
+}
@@ -0,0 +1,86 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	src := "ADD TABLE \"Customer\"\n  AREA \"Data Area\"\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Customer\" \n  AREA \"Index Area\"\n  UNIQUE\n\n" +
+		"ADD INDEX \"Name\" ON \"Customer\" \n  AREA \"Index Area\"\n\n"
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: DataArea
+    index: IndexArea
+  areaMap:
+    Index Area: IdxArea
+  tables:
+    - name: cust*
+      lobArea: CustLob
+    - name: customer
+      indexes:
+        cust*: CustIdx
+        custnum: NumIdx
+  groups:
+    - name: sales
+      tables: [customer]
+      area: SalesData
+  rules:
+    - when: table.fieldCount > 10
+      area: BigTables
+    - from: Data Area
+      to: OldData
+    - when: index.unique
+      area: UniqueIdx
+`)
+	explain := func(name string) string {
+		t.Helper()
+		res, err := Explain(context.Background(), strings.NewReader(src), rules, name, Options{})
+		if err != nil {
+			t.Fatalf("Explain(%s) error = %v", name, err)
+		}
+		var b strings.Builder
+		for _, e := range res.Explanations {
+			fmt.Fprintf(&b, "%s %s:%d %s -> %s\n", e.Kind, e.Name, e.Line, e.Current, e.Area)
+			for _, s := range e.Steps {
+				fmt.Fprintf(&b, "  %s %q %d %s\n", s.Rule, s.Area, s.Line, s.Skipped)
+			}
+		}
+		return b.String()
+	}
+
+	for name, want := range map[string]string{
+		"customer.custnum": `INDEX Customer.CustNum:4 Index Area -> NumIdx
+  areaMap.Index Area "IdxArea" 6 
+  tables[1] (customer) indexes.cust* "CustIdx" 12 "custnum" matches better
+  tables[1] (customer) indexes.custnum "NumIdx" 13 
+`,
+		"Customer.Name": `INDEX Customer.Name:8 Index Area -> IndexArea
+  areaMap.Index Area "IdxArea" 6 
+  tables[1] (customer) "" 10 no entry in indexes and no indexArea
+  tables[0] (cust*) "" 8 no entry in indexes and no indexArea
+  groups[0] (sales) "" 15 no indexArea
+  rules[0] "BigTables" 19 when "table.fieldCount > 10" places tables
+  rules[1] "OldData" 21 from "Data Area", but the area is "IdxArea"
+  rules[2] "UniqueIdx" 23 when "index.unique" is false
+  defaults.index "IndexArea" 4 
+`,
+		"Customer": `TABLE Customer:1 Data Area -> SalesData
+  tables[1] (customer) "" 10 no area
+  tables[0] (cust*) "" 8 no area
+  groups[0] (sales) area "SalesData" 17 
+`,
+	} {
+		if got := explain(name); got != want {
+			t.Errorf("Explain(%s):\n%s\nwant:\n%s", name, got, want)
+		}
+	}
+
+	_, err := Explain(context.Background(), strings.NewReader(src), rules, "Customer.CustNm", Options{})
+	if err == nil || !strings.Contains(err.Error(), `did you mean "Customer.CustNum"?`) {
+		t.Errorf("Explain(Customer.CustNm) error = %v", err)
+	}
+}
@@ -0,0 +1,439 @@
+package schemafixer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A small expression language for the when clause of conditional rules,
+// e.g. `table.hasLobs && table.fieldCount > 50` or
+// `field.type == "clob" && field.lobSize > "100M"`.
+//
+// Operands are attributes of the table, index or LOB field being placed
+// (see attributes), numbers, sizes such as 100M, "strings" and true/false.
+// Operators are ||, &&, !, ==, !=, <, <=, >, >= and parentheses. Strings
+// compare case-insensitively; a string compared with a number is read as a
+// size. Expressions are type checked when the rules are read.
+
+// exprType is the static type of an expression.
+type exprType int
+
+const (
+	typeBool exprType = iota
+	typeNumber
+	typeString
+)
+
+func (t exprType) String() string {
+	return [...]string{"bool", "number", "string"}[t]
+}
+
+// value is the result of evaluating an expression.
+type value struct {
+	b bool
+	n float64
+	s string
+}
+
+// env holds the construct an expression is evaluated for. Index and Field
+// are nil for table rules.
+type env struct {
+	table *TableInfo
+	index *IndexInfo
+	field *FieldInfo
+}
+
+// area returns the current area of the construct of kind in e.
+func (e env) area(kind Kind) string {
+	switch kind {
+	case KindIndex:
+		return e.index.Area
+	case KindLob:
+		return e.field.LobArea
+	}
+	return e.table.Area
+}
+
+// attribute is an operand such as table.fieldCount.
+type attribute struct {
+	kind Kind // the construct the attribute belongs to
+	typ  exprType
+	get  func(env) value
+}
+
+// attributes lists the operands available to when clauses.
+var attributes = map[string]attribute{
+	"table.name":       {KindTable, typeString, func(e env) value { return value{s: e.table.Name} }},
+	"table.dumpName":   {KindTable, typeString, func(e env) value { return value{s: e.table.DumpName} }},
+	"table.area":       {KindTable, typeString, func(e env) value { return value{s: e.table.Area} }},
+	"table.fieldCount": {KindTable, typeNumber, func(e env) value { return value{n: float64(e.table.Fields)} }},
+	"table.indexCount": {KindTable, typeNumber, func(e env) value { return value{n: float64(e.table.Indexes)} }},
+	"table.lobCount":   {KindTable, typeNumber, func(e env) value { return value{n: float64(e.table.Lobs)} }},
+	"table.hasLobs":    {KindTable, typeBool, func(e env) value { return value{b: e.table.Lobs > 0} }},
+
+	"index.name":       {KindIndex, typeString, func(e env) value { return value{s: e.index.Name} }},
+	"index.area":       {KindIndex, typeString, func(e env) value { return value{s: e.index.Area} }},
+	"index.unique":     {KindIndex, typeBool, func(e env) value { return value{b: e.index.Unique} }},
+	"index.primary":    {KindIndex, typeBool, func(e env) value { return value{b: e.index.Primary} }},
+	"index.word":       {KindIndex, typeBool, func(e env) value { return value{b: e.index.Word} }},
+	"index.fieldCount": {KindIndex, typeNumber, func(e env) value { return value{n: float64(e.index.Fields)} }},
+
+	"field.name":    {KindLob, typeString, func(e env) value { return value{s: e.field.Name} }},
+	"field.type":    {KindLob, typeString, func(e env) value { return value{s: e.field.Type} }},
+	"field.lobSize": {KindLob, typeNumber, func(e env) value { return value{n: float64(e.field.LobSize)} }},
+	"field.lobArea": {KindLob, typeString, func(e env) value { return value{s: e.field.LobArea} }},
+}
+
+// tableAggregates are the table attributes that depend on the table's
+// fields and indexes, which follow the ADD TABLE statement in a .df.
+var tableAggregates = map[string]bool{
+	"table.fieldCount": true,
+	"table.indexCount": true,
+	"table.lobCount":   true,
+	"table.hasLobs":    true,
+}
+
+// expr is a compiled when clause.
+type expr struct {
+	kind       Kind // what the rule places: the most specific construct referred to
+	aggregates bool // refers to tableAggregates
+	eval       func(env) value
+}
+
+// compileExpr parses and type checks a when clause.
+func compileExpr(src string) (*expr, error) {
+	p := &exprParser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q at column %d", p.toks[p.pos].text, p.toks[p.pos].col)
+	}
+	if n.typ != typeBool {
+		return nil, fmt.Errorf("condition is a %s, not a bool", n.typ)
+	}
+	if p.refs[KindIndex] && p.refs[KindLob] {
+		return nil, fmt.Errorf("condition refers to both index and field")
+	}
+	e := &expr{kind: KindTable, aggregates: p.aggregates, eval: n.eval}
+	switch {
+	case p.refs[KindIndex]:
+		e.kind = KindIndex
+	case p.refs[KindLob]:
+		e.kind = KindLob
+	case !p.refs[KindTable]:
+		return nil, fmt.Errorf("condition refers to no table, index or field attribute")
+	}
+	return e, nil
+}
+
+// exprToken is a lexical token of an expression.
+type exprToken struct {
+	text string
+	col  int // 1-based
+	kind byte
+}
+
+const (
+	tokIdent  = 'i'
+	tokNumber = 'n'
+	tokString = 's'
+	tokOp     = 'o'
+)
+
+// node is a typed expression tree node.
+type node struct {
+	typ  exprType
+	eval func(env) value
+	lit  *value // set for literals
+}
+
+type exprParser struct {
+	src        string
+	toks       []exprToken
+	pos        int
+	refs       map[Kind]bool
+	aggregates bool
+}
+
+// operators, longest first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+func (p *exprParser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != byte(c) {
+				if s[j] == '\\' && c == '"' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string at column %d", i+1)
+			}
+			text := s[i+1 : j]
+			if c == '"' {
+				var err error
+				if text, err = strconv.Unquote(s[i : j+1]); err != nil {
+					return fmt.Errorf("invalid string at column %d: %w", i+1, err)
+				}
+			}
+			p.toks = append(p.toks, exprToken{text, i + 1, tokString})
+			i = j + 1
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.' || unicode.IsLetter(rune(s[j]))) {
+				j++
+			}
+			p.toks = append(p.toks, exprToken{s[i:j], i + 1, tokNumber})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_' || s[j] == '.') {
+				j++
+			}
+			p.toks = append(p.toks, exprToken{s[i:j], i + 1, tokIdent})
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q at column %d", c, i+1)
+			}
+			p.toks = append(p.toks, exprToken{op, i + 1, tokOp})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peek(op string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == tokOp && p.toks[p.pos].text == op
+}
+
+// logical parses a left-associative chain of op over operands parsed by next.
+func (p *exprParser) logical(op string, next func() (*node, error), combine func(a, b bool) bool) (*node, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek(op) {
+		col := p.toks[p.pos].col
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ != typeBool || right.typ != typeBool {
+			return nil, fmt.Errorf("%s at column %d needs bool operands, got %s and %s", op, col, left.typ, right.typ)
+		}
+		l, r := left.eval, right.eval
+		short := op == "||"
+		left = &node{typ: typeBool, eval: func(e env) value {
+			a := l(e).b
+			if a == short {
+				return value{b: a}
+			}
+			return value{b: combine(a, r(e).b)}
+		}}
+	}
+	return left, nil
+}
+
+func (p *exprParser) or() (*node, error) {
+	return p.logical("||", p.and, func(a, b bool) bool { return a || b })
+}
+
+func (p *exprParser) and() (*node, error) {
+	return p.logical("&&", p.unary, func(a, b bool) bool { return a && b })
+}
+
+func (p *exprParser) unary() (*node, error) {
+	if p.peek("!") {
+		col := p.toks[p.pos].col
+		p.pos++
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if n.typ != typeBool {
+			return nil, fmt.Errorf("! at column %d needs a bool operand, got %s", col, n.typ)
+		}
+		f := n.eval
+		return &node{typ: typeBool, eval: func(e env) value { return value{b: !f(e).b} }}, nil
+	}
+	return p.comparison()
+}
+
+func (p *exprParser) comparison() (*node, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.peek(op) {
+			continue
+		}
+		col := p.toks[p.pos].col
+		p.pos++
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		return compare(op, col, left, right)
+	}
+	return left, nil
+}
+
+// compare builds a comparison node, reading a string literal compared with
+// a number as a size.
+func compare(op string, col int, left, right *node) (*node, error) {
+	for _, pair := range [][2]*node{{left, right}, {right, left}} {
+		if pair[0].typ == typeNumber && pair[1].typ == typeString && pair[1].lit != nil {
+			n, err := parseSize(pair[1].lit.s)
+			if err != nil {
+				return nil, fmt.Errorf("%s at column %d: %w", op, col, err)
+			}
+			pair[1].typ, pair[1].lit.n = typeNumber, float64(n)
+			v := *pair[1].lit
+			pair[1].eval = func(env) value { return v }
+		}
+	}
+	if left.typ != right.typ {
+		return nil, fmt.Errorf("%s at column %d compares a %s with a %s", op, col, left.typ, right.typ)
+	}
+	if left.typ != typeNumber && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("%s at column %d needs number operands, got %s", op, col, left.typ)
+	}
+
+	l, r, typ := left.eval, right.eval, left.typ
+	return &node{typ: typeBool, eval: func(e env) value {
+		a, b := l(e), r(e)
+		var c int
+		switch typ {
+		case typeNumber:
+			c = cmpFloat(a.n, b.n)
+		case typeString:
+			if !strings.EqualFold(a.s, b.s) {
+				c = 1
+			}
+		case typeBool:
+			if a.b != b.b {
+				c = 1
+			}
+		}
+		switch op {
+		case "==":
+			return value{b: c == 0}
+		case "!=":
+			return value{b: c != 0}
+		case "<":
+			return value{b: c < 0}
+		case "<=":
+			return value{b: c <= 0}
+		case ">":
+			return value{b: c > 0}
+		}
+		return value{b: c >= 0}
+	}}, nil
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (p *exprParser) primary() (*node, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokOp:
+		if t.text != "(" {
+			return nil, fmt.Errorf("unexpected %q at column %d", t.text, t.col)
+		}
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing ) for ( at column %d", t.col)
+		}
+		p.pos++
+		return n, nil
+	case tokNumber:
+		n, err := parseSize(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %w", t.col, err)
+		}
+		return literal(typeNumber, value{n: float64(n)}), nil
+	case tokString:
+		return literal(typeString, value{s: t.text}), nil
+	}
+
+	switch t.text {
+	case "true", "false":
+		return literal(typeBool, value{b: t.text == "true"}), nil
+	}
+	a, ok := attributes[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown attribute %q at column %d", t.text, t.col)
+	}
+	if p.refs == nil {
+		p.refs = map[Kind]bool{}
+	}
+	p.refs[a.kind] = true
+	p.aggregates = p.aggregates || tableAggregates[t.text]
+	return &node{typ: a.typ, eval: a.get}, nil
+}
+
+func literal(typ exprType, v value) *node {
+	return &node{typ: typ, lit: &v, eval: func(env) value { return v }}
+}
+
+// parseSize reads a number or a size with a K, M or G suffix (optionally
+// followed by B), as used by LOB-SIZE. Suffixes are powers of 1024.
+func parseSize(s string) (int64, error) {
+	t := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(t, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(t, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(t, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		t = t[:len(t)-1]
+	}
+	n, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
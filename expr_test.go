@@ -0,0 +1,111 @@
+package schemafixer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestApply_ConditionalRules(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  version: 1.0
+  defaults:
+    table: DataArea
+    index: IndexArea
+    lob: LobArea
+  tables:
+    - name: Item
+      area: ItemArea
+  rules:
+    - when: table.hasLobs && table.fieldCount > 1
+      area: LobTables
+    - when: index.word
+      area: WordIdx
+    - when: index.unique && table.name == "order"
+      area: OrderKeys
+    - when: field.type == "clob" && field.lobSize > "100M"
+      area: BigClobs
+    - when: field.lobSize <= 1K
+      area: TinyLobs
+`)
+	src := "ADD TABLE \"Order\"\n" +
+		"  AREA \"Schema Area\"\n" +
+		"\n" +
+		"ADD FIELD \"Notes\" OF \"Order\" AS clob \n" +
+		"  LOB-AREA \"Schema Area\"\n" +
+		"  LOB-BYTES 209715200\n" +
+		"  LOB-SIZE 200M\n" +
+		"\n" +
+		"ADD FIELD \"Thumb\" OF \"Order\" AS blob \n" +
+		"  LOB-AREA \"Schema Area\"\n" +
+		"  LOB-SIZE 1K\n" +
+		"\n" +
+		"ADD INDEX \"OrderNum\" ON \"Order\" \n" +
+		"  AREA \"Schema Area\"\n" +
+		"  UNIQUE\n" +
+		"\n" +
+		"ADD INDEX \"NotesWord\" ON \"Order\" \n" +
+		"  AREA \"Schema Area\"\n" +
+		"  WORD\n" +
+		"\n" +
+		"ADD TABLE \"Item\"\n" +
+		"  AREA \"Schema Area\"\n" +
+		"\n" +
+		"ADD FIELD \"Image\" OF \"Item\" AS blob \n" +
+		"  LOB-AREA \"Schema Area\"\n" +
+		"  LOB-SIZE 10M\n" +
+		"\n" +
+		"ADD FIELD \"Caption\" OF \"Item\" AS character \n" +
+		"\n" +
+		"ADD INDEX \"ItemNum\" ON \"Item\" \n" +
+		"  AREA \"Schema Area\"\n" +
+		"  UNIQUE\n"
+
+	got, out := applyDF(t, src, rules, Options{})
+	want := strings.Join([]string{
+		"Order=LobTables",
+		"Order.Notes=BigClobs",
+		"Order.Thumb=TinyLobs",
+		"Order.OrderNum=OrderKeys",
+		"Order.NotesWord=WordIdx",
+		"Item=ItemArea", // a table rule naming the table beats conditional rules
+		"Item.Image=LobArea",
+		"Item.ItemNum=IndexArea",
+	}, " ")
+	if got != want {
+		t.Errorf("changes:\n%s\nwant:\n%s", got, want)
+	}
+	if !strings.Contains(out, "ADD TABLE \"Order\"\n  AREA \"LobTables\"\n") {
+		t.Errorf("table area not written:\n%s", out)
+	}
+
+	// The conditions of rules built in Go are compiled by Apply, into a copy.
+	built := *rules
+	built.Rules = nil
+	for _, rule := range rules.Rules {
+		built.Rules = append(built.Rules, AreaRule{When: rule.When, Area: rule.Area})
+	}
+	if got, _ := applyDF(t, src, &built, Options{}); got != want || built.Rules[0].cond != nil {
+		t.Errorf("Apply() of rules built in Go: changes %s", got)
+	}
+}
+
+func TestReadRules_InvalidCondition(t *testing.T) {
+	tests := []struct{ when, want string }{
+		{"table.hasLob", `rules[0]: when "table.hasLob": unknown attribute "table.hasLob" at column 1`},
+		{"table.fieldCount > 50 &&", "unexpected end of condition"},
+		{"table.fieldCount", "condition is a number, not a bool"},
+		{`table.name > "a"`, "> at column 12 needs number operands, got string"},
+		{`field.lobSize > "big"`, `invalid size "big"`},
+		{"index.word && field.lobSize > 0", "refers to both index and field"},
+		{"true", "refers to no table, index or field attribute"},
+		{"(index.word", "missing ) for ( at column 1"},
+	}
+	for _, tt := range tests {
+		src := fmt.Sprintf("schemafixer:\n  rules:\n    - when: '%s'\n      area: A\n", tt.when)
+		_, err := ReadRules(strings.NewReader(src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ReadRules(when %s) error = %v, want %q", tt.when, err, tt.want)
+		}
+	}
+}
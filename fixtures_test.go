@@ -0,0 +1,90 @@
+package schemafixer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const testRules = `schemafixer:
+  version: 1.0
+  defaults:
+    table: DataArea
+    index: IndexArea
+    lob: LobArea
+  tables:
+    - name: customer
+      area: data
+      indexes:
+        custnum: index1
+    - name: item
+      lobs:
+        ItemImage: lob1
+`
+
+const testDF = "ADD TABLE \"Customer\"\n" +
+	"  AREA \"Schema Area\"\n" +
+	"\n" +
+	"ADD INDEX \"CustNum\" ON \"Customer\" \n" +
+	"  AREA \"Schema Area\"\n" +
+	"  UNIQUE\n" +
+	"\n" +
+	"ADD TABLE \"Item\"\n" +
+	"  AREA \"Schema Area\"\n" +
+	"\n" +
+	"ADD FIELD \"ItemImage\" OF \"Item\" AS blob \n" +
+	"  LOB-AREA \"Schema Area\"\n" +
+	"\n" +
+	".\n" +
+	"PSC\n" +
+	"cpstream=UTF-8\n" +
+	".\n" +
+	"0000000000\n"
+
+// trailerOffset returns the byte count of the .df body, which ends before
+// the byte count line: the offset just past the "." that opens its trailer.
+func trailerOffset(body string) int {
+	eol := "\n"
+	if strings.Contains(body, "\r\n") {
+		eol = "\r\n"
+	}
+	return strings.Index(body, eol+"."+eol) + len(eol+"."+eol)
+}
+
+func mustRules(t *testing.T, src string) *SchemaFixerRules {
+	t.Helper()
+	rf, err := ReadRules(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ReadRules() error = %v", err)
+	}
+	return &rf.SchemaFixer
+}
+
+// areaOf returns a function that returns the area of a lookup such as
+// TableAreaFor, failing t on an error.
+func areaOf(t *testing.T) func(string, error) string {
+	return func(area string, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return area
+	}
+}
+
+// applyDF applies rules to the .df src, failing t on an error. It returns
+// the changes, as "name=area" separated by spaces, and the output.
+func applyDF(t *testing.T, src string, rules *SchemaFixerRules, opts Options) (string, string) {
+	t.Helper()
+	var out bytes.Buffer
+	res, err := Apply(context.Background(), strings.NewReader(src), rules, &out, opts)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	var changes []string
+	for _, c := range res.Changes {
+		changes = append(changes, c.Name+"="+c.To)
+	}
+	return strings.Join(changes, " "), out.String()
+}
@@ -0,0 +1,87 @@
+package schemafixer
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// FlattenArea is the area every AREA/LOB-AREA value is reset to by Flatten.
+const FlattenArea = "Schema Area"
+
+// FlattenResult counts what Flatten changed.
+type FlattenResult struct {
+	Areas      int // AREA values reset
+	LobAreas   int // LOB-AREA values reset
+	CanDeleted int // CAN-* permission lines removed
+
+	// ByteCount is the regenerated trailer byte count, or -1 if the input
+	// had none.
+	ByteCount int64
+}
+
+// Flatten reads a .df from in, resets every AREA and LOB-AREA value to
+// FlattenArea, removes all CAN-* lines and writes the result to out. The
+// trailer byte count is regenerated for the new content.
+//
+// The .df trailer declares its own codepage via a "cpstream=<name>" line,
+// so no encoding assumption is made here. The file is treated as a raw byte
+// sequence since AREA/LOB-AREA/CAN- constructs are always plain ASCII; any
+// multi-byte payload elsewhere in the file (descriptions, labels, etc.) is
+// passed through untouched regardless of its codepage. Problems with the
+// input, such as a truncated file, are passed to opts.Report.
+func Flatten(ctx context.Context, in io.Reader, out io.Writer, opts Options) (*FlattenResult, error) {
+	res := &FlattenResult{ByteCount: -1}
+	enc := df.NewEncoder(out)
+	enc.EOL = opts.EOL
+
+	err := decode(ctx, in, nil, newChecker(in, opts), func(n df.Node) error {
+		// Stray attribute lines outside any statement are flattened too.
+		switch n := n.(type) {
+		case df.Statement:
+			res.flattenAreas(n.Base().Attrs)
+			res.CanDeleted += n.Base().RemoveAttrs(isCanAttr)
+		case *df.Raw:
+			res.flattenAreas(n.Attrs)
+			res.CanDeleted += n.RemoveAttrs(isCanAttr)
+		case *df.Trailer:
+			return encodeTrailer(enc, n, &res.ByteCount, opts.Logger)
+		}
+		return enc.Encode(n)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Logger.Debug().
+		Int("area", res.Areas).
+		Int("lobArea", res.LobAreas).
+		Int("canDeleted", res.CanDeleted).
+		Msg("flatten complete")
+	return res, nil
+}
+
+// flattenAreas resets every AREA and LOB-AREA value in attrs to FlattenArea.
+func (res *FlattenResult) flattenAreas(attrs []*df.Attribute) {
+	for _, a := range attrs {
+		if len(a.Args) == 0 || a.Args[0].Kind != df.String {
+			continue
+		}
+		switch {
+		case a.Is("AREA"):
+			res.Areas++
+		case a.Is("LOB-AREA"):
+			res.LobAreas++
+		default:
+			continue
+		}
+		a.SetValue(FlattenArea)
+	}
+}
+
+// isCanAttr reports whether a is a CAN-READ, CAN-WRITE, ... permission.
+func isCanAttr(a *df.Attribute) bool {
+	return strings.HasPrefix(strings.ToUpper(a.Keyword), "CAN-")
+}
@@ -0,0 +1,26 @@
+package schemafixer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	src := strings.Replace(testDF, "  AREA \"Schema Area\"\n", "  AREA \"Data\"\n  CAN-READ \"*\"\n", 1)
+	var out bytes.Buffer
+	res, err := Flatten(context.Background(), strings.NewReader(src), &out, Options{})
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	body := strings.TrimSuffix(testDF, "0000000000\n")
+	want := body + fmt.Sprintf("%010d\n", trailerOffset(body))
+	if out.String() != want {
+		t.Errorf("Flatten() output mismatch\ngot:  %q\nwant: %q", out.String(), want)
+	}
+	if *res != (FlattenResult{Areas: 3, LobAreas: 1, CanDeleted: 1, ByteCount: int64(trailerOffset(body))}) {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,133 @@
+package schemafixer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Group places a set of tables, and their indexes and LOB fields, in the
+// same areas. Tables lists its members by name or pattern (see MatchKind).
+// A table in several groups belongs to the one with the highest Priority;
+// two groups of equal priority claiming a table is an error.
+type Group struct {
+	Name      string   `yaml:"name"`
+	Tables    []string `yaml:"tables"`
+	Area      string   `yaml:"area,omitempty"`
+	IndexArea string   `yaml:"indexArea,omitempty"`
+	LobArea   string   `yaml:"lobArea,omitempty"`
+	Priority  int      `yaml:"priority,omitempty"`
+
+	src source
+	pos map[string]source // "area", "indexArea" and "lobArea"
+}
+
+// Group returns the group table belongs to, or nil. If the table is in
+// several groups of the same highest priority, the first of them in file
+// order is returned together with an error naming them.
+func (r *SchemaFixerRules) Group(table string) (*Group, error) {
+	var best []*Group
+	for i := range r.Groups {
+		g := &r.Groups[i]
+		if !slices.ContainsFunc(g.Tables, func(p string) bool { return match(p, table) != NoMatch }) {
+			continue
+		}
+		switch {
+		case len(best) == 0 || g.Priority > best[0].Priority:
+			best = []*Group{g}
+		case g.Priority == best[0].Priority:
+			best = append(best, g)
+		}
+	}
+	switch len(best) {
+	case 0:
+		return nil, nil
+	case 1:
+		return best[0], nil
+	}
+	names := make([]string, len(best))
+	for i, g := range best {
+		names[i] = fmt.Sprintf("%q", g.Name)
+		if g.src.line > 0 {
+			names[i] += " (" + g.src.String() + ")"
+		}
+	}
+	return best[0], fmt.Errorf("table %q is in groups %s with the same priority; give one of them a higher priority", table, strings.Join(names, " and "))
+}
+
+// validateGroups checks that groups have unique names and valid member
+// patterns.
+func (r *SchemaFixerRules) validateGroups() error {
+	for i, g := range r.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("groups[%d]: no name", i)
+		}
+		if j := slices.IndexFunc(r.Groups[:i], func(o Group) bool { return strings.EqualFold(o.Name, g.Name) }); j >= 0 {
+			return fmt.Errorf("groups[%d]: group %q is already defined as groups[%d]", i, g.Name, j)
+		}
+		for _, p := range g.Tables {
+			if _, err := compilePattern(p); err != nil {
+				return fmt.Errorf("groups[%d] (%s) tables: %w", i, g.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GroupTables replaces the table entries of r that only set areas (no
+// indexes or lobs entries) and share the same area, indexArea and lobArea
+// with a group per such combination, if at least two tables share it. It is
+// used by parse to write compact rules.
+func (r *SchemaFixerRules) GroupTables() {
+	type triple struct{ area, indexArea, lobArea string }
+	var order []triple
+	members := map[triple][]string{}
+	for _, t := range r.Tables {
+		if len(t.Indexes) > 0 || len(t.Lobs) > 0 {
+			continue
+		}
+		k := triple{t.Area, t.IndexArea, t.LobArea}
+		if members[k] == nil {
+			order = append(order, k)
+		}
+		members[k] = append(members[k], t.Name)
+	}
+
+	grouped := map[string]bool{}
+	for _, k := range order {
+		if len(members[k]) < 2 {
+			continue
+		}
+		name := groupName(r.Groups, k.area, k.indexArea, k.lobArea)
+		r.Groups = append(r.Groups, Group{Name: name, Tables: members[k], Area: k.area, IndexArea: k.indexArea, LobArea: k.lobArea})
+		for _, t := range members[k] {
+			grouped[strings.ToLower(t)] = true
+		}
+	}
+	r.Tables = slices.DeleteFunc(r.Tables, func(t TableRule) bool { return grouped[strings.ToLower(t.Name)] })
+}
+
+// groupName returns a name for a group of tables in the given areas: the
+// first area that is set, with a number appended if groups already has it.
+func groupName(groups []Group, areas ...string) string {
+	base := "group"
+	for _, a := range areas {
+		if a != "" {
+			base = a
+			break
+		}
+	}
+	name := base
+	for n := 2; slices.ContainsFunc(groups, func(g Group) bool { return strings.EqualFold(g.Name, name) }); n++ {
+		name = fmt.Sprintf("%s %d", base, n)
+	}
+	return name
+}
+
+// at returns where key of g is defined, or g itself if unknown.
+func (g *Group) at(key string) source {
+	if s, ok := g.pos[key]; ok {
+		return s
+	}
+	return g.src
+}
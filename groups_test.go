@@ -0,0 +1,118 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRules_Groups(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: DataArea
+    index: IndexArea
+  tables:
+    - name: order
+      area: OrderData
+  groups:
+    - name: sales
+      tables: [order, orderline, invoice]
+      area: SalesData
+      indexArea: SalesIdx
+    - name: history
+      tables: ["*hist"]
+      area: HistData
+    - name: archive
+      tables: [orderhist, invoice]
+      area: ArchiveData
+      priority: 1
+  rules:
+    - when: table.name == "orderline" || table.name == "custhist"
+      area: RuleData
+`)
+	area := areaOf(t)
+	checks := []struct{ got, want string }{
+		{rules.TableArea("order"), "OrderData"},                                // a table entry beats its group
+		{rules.IndexArea("order", "OrderNum"), "SalesIdx"},                     // ... but only for what it sets
+		{area(rules.TableAreaFor(&TableInfo{Name: "orderline"})), "SalesData"}, // a group beats rules
+		{area(rules.TableAreaFor(&TableInfo{Name: "custhist"})), "HistData"},
+		{rules.IndexArea("custhist", "x"), "IndexArea"},
+		{rules.TableArea("orderhist"), "ArchiveData"}, // the higher priority wins
+		{rules.TableArea("invoice"), "ArchiveData"},
+		{rules.TableArea("item"), "DataArea"},
+	}
+	for i, c := range checks {
+		if c.got != c.want {
+			t.Errorf("check %d: got %q, want %q", i, c.got, c.want)
+		}
+	}
+}
+
+func TestApply_GroupConflict(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: DataArea
+  groups:
+    - name: sales
+      tables: [order*]
+      area: SalesData
+    - name: history
+      tables: ["*hist"]
+      area: HistData
+`)
+	src := "ADD TABLE \"Order\"\n  AREA \"Schema Area\"\n\n" +
+		"ADD TABLE \"OrderHist\"\n  AREA \"Schema Area\"\n\n"
+
+	var got []string
+	opts := Options{Report: func(d Diagnostic) { got = append(got, d.String()) }}
+	if _, err := Apply(context.Background(), strings.NewReader(src), rules, io.Discard, opts); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		`4:1: error: table "OrderHist" is in groups "sales" (<rules>:5) and "history" (<rules>:8) with the same priority; give one of them a higher priority`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRules_GroupTables(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  tables:
+    - name: order
+      area: SalesData
+      indexArea: SalesIdx
+    - name: orderline
+      area: SalesData
+      indexArea: SalesIdx
+    - name: item
+      area: SalesData
+    - name: customer
+      area: SalesData
+      indexes:
+        custnum: CustIdx
+    - name: invoice
+      area: SalesData
+      indexArea: SalesIdx
+  groups:
+    - name: SalesData
+      tables: [other]
+`)
+	rules.GroupTables()
+
+	var tables, groups []string
+	for _, tr := range rules.Tables {
+		tables = append(tables, tr.Name)
+	}
+	for _, g := range rules.Groups {
+		groups = append(groups, fmt.Sprintf("%s %v %s/%s", g.Name, g.Tables, g.Area, g.IndexArea))
+	}
+	if got, want := strings.Join(tables, ","), "item,customer"; got != want {
+		t.Errorf("tables = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(groups, "; "), "SalesData [other] /; SalesData 2 [order orderline invoice] SalesData/SalesIdx"; got != want {
+		t.Errorf("groups = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package schemafixer
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MatchKind says how a rule name matched a table, index or LOB field name.
+// Lower kinds take precedence: an exact name beats a regex, and a regex
+// beats a glob.
+type MatchKind int
+
+const (
+	MatchExact MatchKind = iota // the name itself, compared case-insensitively
+	MatchRegex                  // a regular expression between slashes, e.g. /^tmp[A-Z]/
+	MatchGlob                   // a glob with *, ? or [...], e.g. hist_*
+	NoMatch
+)
+
+// String returns the match kind as shown to users.
+func (k MatchKind) String() string {
+	switch k {
+	case MatchExact:
+		return "exact"
+	case MatchRegex:
+		return "regex"
+	case MatchGlob:
+		return "glob"
+	}
+	return "none"
+}
+
+// pattern is a compiled rule name.
+type pattern struct {
+	kind MatchKind
+	text string         // lower-cased name or glob
+	re   *regexp.Regexp // for MatchRegex
+}
+
+// patterns caches compiled patterns; rules are matched against every
+// construct of a .df.
+var patterns sync.Map // string → *pattern
+
+// compilePattern parses a rule name. Regexes and globs match
+// case-insensitively, like OpenEdge names.
+func compilePattern(s string) (*pattern, error) {
+	switch {
+	case len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/"):
+		re, err := regexp.Compile("(?i)" + s[1:len(s)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %w", s, err)
+		}
+		return &pattern{kind: MatchRegex, re: re}, nil
+	case strings.ContainsAny(s, "*?["):
+		text := strings.ToLower(s)
+		if _, err := path.Match(text, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", s, err)
+		}
+		return &pattern{kind: MatchGlob, text: text}, nil
+	}
+	return &pattern{kind: MatchExact, text: s}, nil
+}
+
+// match returns how the rule name s matches name, or NoMatch. Invalid
+// patterns, which ReadRules rejects, never match.
+func match(s, name string) MatchKind {
+	p, ok := patterns.Load(s)
+	if !ok {
+		cp, err := compilePattern(s)
+		if err != nil {
+			cp = &pattern{kind: NoMatch}
+		}
+		p, _ = patterns.LoadOrStore(s, cp)
+	}
+	pat := p.(*pattern)
+
+	switch pat.kind {
+	case MatchExact:
+		if strings.EqualFold(s, name) {
+			return MatchExact
+		}
+	case MatchRegex:
+		if pat.re.MatchString(name) {
+			return MatchRegex
+		}
+	case MatchGlob:
+		if ok, _ := path.Match(pat.text, strings.ToLower(name)); ok {
+			return MatchGlob
+		}
+	}
+	return NoMatch
+}
+
+// lookup returns the key and value of the entry of m whose key matches name
+// best. Between keys of the same kind the longer, more specific one wins,
+// and then the alphabetically first, so the result does not depend on map
+// order.
+func lookup(m map[string]string, name string) (key, value string, kind MatchKind) {
+	kind = NoMatch
+	for k, v := range m {
+		mk := match(k, name)
+		if mk == NoMatch {
+			continue
+		}
+		if mk < kind || mk == kind && (len(k) > len(key) || len(k) == len(key) && k < key) {
+			key, value, kind = k, v, mk
+		}
+	}
+	return key, value, kind
+}
+
+// tableMatch is a table rule that matches a table name.
+type tableMatch struct {
+	rule  *TableRule
+	kind  MatchKind
+	index int // in SchemaFixerRules.Tables
+}
+
+// path returns how the rule is shown by Explain, e.g. "tables[2] (cust*)".
+func (m tableMatch) path() string {
+	return fmt.Sprintf("tables[%d] (%s)", m.index, m.rule.Name)
+}
+
+// tableRules returns the table rules matching table, best first: exact
+// names, then regexes, then globs, each in file order.
+func (r *SchemaFixerRules) tableRules(table string) []tableMatch {
+	var matches []tableMatch
+	for i := range r.Tables {
+		if mk := match(r.Tables[i].Name, table); mk != NoMatch {
+			matches = append(matches, tableMatch{&r.Tables[i], mk, i})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].kind < matches[j].kind })
+	return matches
+}
+
+// validatePatterns checks that every table, index and LOB name of r is a
+// valid pattern.
+func (r *SchemaFixerRules) validatePatterns() error {
+	for i, t := range r.Tables {
+		if _, err := compilePattern(t.Name); err != nil {
+			return fmt.Errorf("tables[%d]: %w", i, err)
+		}
+		for k := range t.Indexes {
+			if _, err := compilePattern(k); err != nil {
+				return fmt.Errorf("tables[%d] (%s) indexes: %w", i, t.Name, err)
+			}
+		}
+		for k := range t.Lobs {
+			if _, err := compilePattern(k); err != nil {
+				return fmt.Errorf("tables[%d] (%s) lobs: %w", i, t.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package schemafixer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRules_Patterns(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  version: 1.0
+  defaults:
+    table: DataArea
+    index: IndexArea
+    lob: LobArea
+  tables:
+    - name: hist_*
+      area: HistGlob
+      indexes:
+        "*": HistIdx
+    - name: /^hist_[a-z]+$/
+      area: HistRegex
+    - name: hist_order
+      area: HistOrder
+      indexes:
+        custnum: OrderCust
+        cust*: OrderGlob
+        c*: OrderShortGlob
+        /^cust/: OrderRegex
+      lobs:
+        "[ab]*": LobGlob
+`)
+
+	tables := []struct{ name, want string }{
+		{"hist_order", "HistOrder"},
+		{"HIST_ITEM", "HistRegex"},
+		{"hist_2024", "HistGlob"},
+		{"customer", "DataArea"},
+	}
+	for _, tt := range tables {
+		if got := rules.TableArea(tt.name); got != tt.want {
+			t.Errorf("TableArea(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	indexes := []struct{ table, index, want string }{
+		{"hist_order", "CustNum", "OrderCust"},
+		{"hist_order", "CustName", "OrderRegex"},
+		{"hist_order", "Comments", "OrderShortGlob"},
+		{"hist_order", "OrderNum", "HistIdx"}, // falls through to the glob table rule
+		{"customer", "CustNum", "IndexArea"},
+	}
+	for _, tt := range indexes {
+		if got := rules.IndexArea(tt.table, tt.index); got != tt.want {
+			t.Errorf("IndexArea(%q, %q) = %q, want %q", tt.table, tt.index, got, tt.want)
+		}
+	}
+
+	if got := rules.LobArea("hist_order", "Attachment"); got != "LobGlob" {
+		t.Errorf("LobArea() = %q, want LobGlob", got)
+	}
+	if got := rules.LobArea("hist_order", "Image"); got != "LobArea" {
+		t.Errorf("LobArea() = %q, want LobArea", got)
+	}
+}
+
+func TestReadRules_InvalidPattern(t *testing.T) {
+	_, err := ReadRules(strings.NewReader("schemafixer:\n  tables:\n    - name: /^tmp(/\n"))
+	if err == nil || !strings.Contains(err.Error(), "tables[0]: invalid regex") {
+		t.Errorf("ReadRules() error = %v, want an invalid regex error", err)
+	}
+}
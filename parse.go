@@ -0,0 +1,193 @@
+package schemafixer
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// ParseRules is the reverse of Apply: it reads a .df from in and returns a
+// rules file that records every table, index and LOB area that differs from
+// the defaults in base. Applying the result to the same .df with the same
+// defaults reproduces its areas.
+func ParseRules(ctx context.Context, in io.Reader, base *SchemaFixerRules, opts Options) (*RulesFile, error) {
+	log := opts.Logger
+	defaults := base.Defaults
+	log.Debug().
+		Str("defaultTable", defaults.Table).
+		Str("defaultIndex", defaults.Index).
+		Str("defaultLob", defaults.Lob).
+		Msg("parse started")
+
+	_, cp, err := codepage(in, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// tableMap accumulates per-table rules keyed by lowercased table name.
+	// We also keep insertion order via a separate slice.
+	type tableEntry struct {
+		name    string // original casing from .df
+		area    string // non-default area, empty = use default
+		indexes map[string]string
+		lobs    map[string]string
+	}
+	tableOrder := []string{} // lower-cased names, insertion order
+	tableMap := map[string]*tableEntry{}
+
+	// shared tracks the areas of all indexes and LOB fields of each table,
+	// "" for a missing AREA or LOB-AREA, with their defaults, to find the
+	// tables whose indexes or LOBs all share one area that is not the default
+	// of all of them.
+	type areas struct{ indexes, lobs []placed }
+	shared := map[string]*areas{}
+	note := func(tableName string) *areas {
+		key := strings.ToLower(tableName)
+		if shared[key] == nil {
+			shared[key] = &areas{}
+		}
+		return shared[key]
+	}
+
+	// dumpNames holds the DUMP-NAME of each table, for area templates.
+	dumpNames := map[string]string{}
+	data := func(tableName string) areaData {
+		return areaData{Table: tableName, DumpName: dumpNames[strings.ToLower(tableName)]}
+	}
+
+	getOrCreate := func(tableName string) *tableEntry {
+		key := strings.ToLower(tableName)
+		if _, ok := tableMap[key]; !ok {
+			tableMap[key] = &tableEntry{
+				name:    tableName,
+				indexes: map[string]string{},
+				lobs:    map[string]string{},
+			}
+			tableOrder = append(tableOrder, key)
+		}
+		return tableMap[key]
+	}
+
+	err = decode(ctx, in, cp, newChecker(in, opts), func(n df.Node) error {
+		switch n := n.(type) {
+		case *df.Table:
+			log.Debug().Str("table", n.Name).Msg("parsing TABLE")
+			dumpNames[strings.ToLower(n.Name)] = n.AttrValue("DUMP-NAME")
+			def, err := base.expand(base.defaultArea("table", defaults.Table), KindTable, n.Name, data(n.Name))
+			if err != nil {
+				return err
+			}
+			if a := n.Attr("AREA"); a != nil {
+				area := a.Value()
+				if !isDefault(area, def) {
+					e := getOrCreate(n.Name)
+					e.area = area
+					log.Debug().Str("table", n.Name).Str("area", area).Msg("non-default TABLE area")
+				}
+			}
+
+		case *df.Index:
+			log.Debug().Str("index", n.Name).Str("table", n.Table).Msg("parsing INDEX")
+			d := data(n.Table)
+			d.Index = n.Name
+			def, err := base.expand(base.defaultArea(defaults.indexDefault(indexInfo(n))), KindIndex, n.Table+"."+n.Name, d)
+			if err != nil {
+				return err
+			}
+			note(n.Table).indexes = append(note(n.Table).indexes, placed{n.Area(), def})
+			if a := n.Attr("AREA"); a != nil {
+				area := a.Value()
+				if !isDefault(area, def) {
+					e := getOrCreate(n.Table)
+					e.indexes[strings.ToLower(n.Name)] = area
+					log.Debug().Str("index", n.Name).Str("table", n.Table).Str("area", area).Msg("non-default INDEX area")
+				}
+			}
+
+		case *df.Field:
+			log.Debug().Str("field", n.Name).Str("table", n.Table).Msg("parsing FIELD")
+			if !n.IsLob() && !n.HasAttr("LOB-AREA") {
+				return nil
+			}
+			d := data(n.Table)
+			d.Field = n.Name
+			def, err := base.expand(base.defaultArea(defaults.lobDefault(fieldInfo(n))), KindLob, n.Table+"."+n.Name, d)
+			if err != nil {
+				return err
+			}
+			note(n.Table).lobs = append(note(n.Table).lobs, placed{n.LobArea(), def})
+			if a := n.Attr("LOB-AREA"); a != nil {
+				area := a.Value()
+				if !isDefault(area, def) {
+					e := getOrCreate(n.Table)
+					e.lobs[n.Name] = area
+					log.Debug().Str("field", n.Name).Str("table", n.Table).Str("area", area).Msg("non-default LOB area")
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the output RulesFile — defaults come first, then per-table rules.
+	out := &RulesFile{
+		SchemaFixer: SchemaFixerRules{
+			Version:  RulesVersion,
+			Defaults: defaults,
+		},
+	}
+
+	for _, key := range tableOrder {
+		e := tableMap[key]
+		tr := TableRule{
+			Name: e.name,
+			Area: e.area,
+		}
+		// A table whose indexes (or LOB fields) all share one non-default
+		// area gets a single indexArea (or lobArea) instead of one entry
+		// per index.
+		if area, ok := sharedArea(note(e.name).indexes); ok {
+			tr.IndexArea = area
+		} else if len(e.indexes) > 0 {
+			tr.Indexes = e.indexes
+		}
+		if area, ok := sharedArea(note(e.name).lobs); ok {
+			tr.LobArea = area
+		} else if len(e.lobs) > 0 {
+			tr.Lobs = e.lobs
+		}
+		out.SchemaFixer.Tables = append(out.SchemaFixer.Tables, tr)
+	}
+
+	log.Debug().Int("tables", len(out.SchemaFixer.Tables)).Msg("parse complete")
+	return out, nil
+}
+
+// placed is the area of an index or LOB field and its default area.
+type placed struct{ area, def string }
+
+// sharedArea returns the area all of ps are in, if there is one and it is
+// not the default of all of them.
+func sharedArea(ps []placed) (string, bool) {
+	if len(ps) == 0 || ps[0].area == "" {
+		return "", false
+	}
+	deviates := false
+	for _, p := range ps {
+		if p.area != ps[0].area {
+			return "", false
+		}
+		deviates = deviates || !isDefault(p.area, p.def)
+	}
+	return ps[0].area, deviates
+}
+
+// isDefault reports whether area is the default def. Every area is when
+// the default is Keep.
+func isDefault(area, def string) bool {
+	return def == Keep || strings.EqualFold(area, def)
+}
@@ -0,0 +1,65 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	src := strings.Replace(testDF, "Schema Area", "data", 1)
+	src = strings.Replace(src, "Schema Area", "IndexArea", 1)
+	base := mustRules(t, "schemafixer:\n  version: 1.0\n  defaults:\n    table: Schema Area\n    index: IndexArea\n    lob: Schema Area\n")
+
+	rf, err := ParseRules(context.Background(), strings.NewReader(src), base, Options{})
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	got := rf.SchemaFixer
+	if got.Defaults != base.Defaults || got.Version != RulesVersion {
+		t.Errorf("defaults/version not carried over: %+v", got)
+	}
+	if len(got.Tables) != 1 || got.Tables[0].Name != "Customer" || got.Tables[0].Area != "data" {
+		t.Errorf("unexpected tables: %+v", got.Tables)
+	}
+}
+
+func TestParseRules_SharedAreas(t *testing.T) {
+	src := "ADD TABLE \"Order\"\n  AREA \"Data\"\n\n" +
+		"ADD FIELD \"Notes\" OF \"Order\" AS clob \n  LOB-AREA \"Lobs\"\n\n" +
+		"ADD FIELD \"Image\" OF \"Order\" AS blob \n  LOB-AREA \"Lobs\"\n\n" +
+		"ADD INDEX \"OrderNum\" ON \"Order\" \n  AREA \"OrderIdx\"\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Order\" \n  AREA \"OrderIdx\"\n\n" +
+		"ADD TABLE \"Item\"\n  AREA \"Data\"\n\n" +
+		"ADD INDEX \"ItemNum\" ON \"Item\" \n  AREA \"ItemIdx\"\n\n" +
+		"ADD INDEX \"ItemName\" ON \"Item\" \n  AREA \"IndexArea\"\n\n" +
+		"ADD TABLE \"Bin\"\n  AREA \"Data\"\n\n" +
+		"ADD INDEX \"BinNum\" ON \"Bin\" \n  AREA \"BinIdx\"\n\n" +
+		"ADD INDEX \"BinItem\" ON \"Bin\" \n\n"
+	base := mustRules(t, "schemafixer:\n  defaults:\n    table: DataArea\n    index: IndexArea\n    lob: LobArea\n")
+
+	rf, err := ParseRules(context.Background(), strings.NewReader(src), base, Options{})
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	var got []string
+	for _, tr := range rf.SchemaFixer.Tables {
+		got = append(got, fmt.Sprintf("%s indexArea=%q lobArea=%q indexes=%v lobs=%v", tr.Name, tr.IndexArea, tr.LobArea, tr.Indexes, tr.Lobs))
+	}
+	want := []string{
+		`Order indexArea="OrderIdx" lobArea="Lobs" indexes=map[] lobs=map[]`,
+		`Item indexArea="" lobArea="" indexes=map[itemnum:ItemIdx] lobs=map[]`,
+		// An index without AREA is not in BinIdx, so no indexArea.
+		`Bin indexArea="" lobArea="" indexes=map[binnum:BinIdx] lobs=map[]`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("tables:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Applying the result reproduces the areas.
+	_, out := applyDF(t, src, &rf.SchemaFixer, Options{})
+	if want := strings.Replace(src, "\"BinItem\" ON \"Bin\" \n", "\"BinItem\" ON \"Bin\" \n  AREA \"IndexArea\"\n", 1); out != want {
+		t.Errorf("round trip:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -51,15 +51,18 @@ except ImportError:  # pragma: no cover
     sys.exit(1)
 
 VERSION = "dev"
+# Version of the rules format parse writes; mirrors RulesVersion in version.go.
+RULES_VERSION = 1.1
 
 log = logging.getLogger("schemafixer")
 
 # ── Regular expressions for .df construct detection and area replacement ──
 RE_ADD_TABLE = re.compile(r'^ADD TABLE "([^"]+)"', re.IGNORECASE)
-RE_ADD_FIELD = re.compile(r'^ADD FIELD "([^"]+)" OF "([^"]+)"', re.IGNORECASE)
+RE_ADD_FIELD = re.compile(r'^ADD FIELD "([^"]+)" OF "([^"]+)"(?: AS (\S+))?', re.IGNORECASE)
 RE_ADD_INDEX = re.compile(r'^ADD INDEX "([^"]+)" ON "([^"]+)"', re.IGNORECASE)
 RE_ADD_SEQUENCE = re.compile(r'^ADD SEQUENCE ', re.IGNORECASE)
 RE_CHECKSUM = re.compile(r'^\d{10}$')
+RE_INDEX_FLAG = re.compile(r'^\s+(UNIQUE|PRIMARY|WORD)\s*$', re.IGNORECASE)
 RE_AREA = re.compile(r'^(  AREA ")([^"]+)(".*$)')
 RE_LOB_AREA = re.compile(r'^(  LOB-AREA ")([^"]+)(".*$)')
 
@@ -91,6 +94,8 @@ class AreaDefaults:
 class TableRule:
     name: str = ""
     area: str = ""
+    index_area: str = ""  # every index without an entry in indexes
+    lob_area: str = ""  # every LOB field without an entry in lobs
     indexes: dict[str, str] = field(default_factory=dict)
     lobs: dict[str, str] = field(default_factory=dict)
 
@@ -113,6 +118,8 @@ class SchemaFixerRules:
                 for k, v in t.indexes.items():
                     if k.lower() == index_name.lower():
                         return v
+                if t.index_area:
+                    return t.index_area
         return self.defaults.index
 
     def lob_area(self, table_name: str, field_name: str) -> str:
@@ -121,6 +128,8 @@ class SchemaFixerRules:
                 for k, v in t.lobs.items():
                     if k.lower() == field_name.lower():
                         return v
+                if t.lob_area:
+                    return t.lob_area
         return self.defaults.lob
 
 
@@ -153,6 +162,8 @@ def load_rules(path: str) -> SchemaFixerRules:
             TableRule(
                 name=t.get("name", "") or "",
                 area=t.get("area", "") or "",
+                index_area=t.get("indexArea", "") or "",
+                lob_area=t.get("lobArea", "") or "",
                 indexes={str(k): str(v) for k, v in (t.get("indexes") or {}).items()},
                 lobs={str(k): str(v) for k, v in (t.get("lobs") or {}).items()},
             )
@@ -240,6 +251,24 @@ def process_df(lines: list[str], rules: SchemaFixerRules, line_ending: str) -> s
     return "".join(out)
 
 
+def trailer_offset(content: str, line_ending: str) -> int:
+    """Return the byte count OpenEdge writes in the trailer of *content*.
+
+    That is the offset of the PSC line, just past the "." line that opens the
+    trailer, in bytes (content is latin-1, so one character is one byte). Falls
+    back to the length of *content* when it has no trailer.
+    """
+    pos = 0
+    while pos < len(content):
+        end = content.find(line_ending, pos)
+        if end < 0:
+            break
+        if content[pos:end] == ".":
+            return end + len(line_ending)
+        pos = end + len(line_ending)
+    return len(content)
+
+
 def run_apply(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
     log.debug("apply started df=%s rules=%s output=%s", df_path, rules_path, output_path)
 
@@ -268,14 +297,14 @@ def run_apply(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
             f.write(buf.encode("latin-1"))
             log.debug("writing to file path=%s", output_path)
             if has_checksum:
-                byte_count = len(buf.encode("latin-1"))
+                byte_count = trailer_offset(buf, line_ending)
                 checksum_line = f"{byte_count:010d}{line_ending}"
                 f.write(checksum_line.encode("latin-1"))
                 log.debug("checksum written byteCount=%d", byte_count)
     else:
         sys.stdout.buffer.write(buf.encode("latin-1"))
         if has_checksum:
-            byte_count = len(buf.encode("latin-1"))
+            byte_count = trailer_offset(buf, line_ending)
             checksum_line = f"{byte_count:010d}{line_ending}"
             sys.stdout.buffer.write(checksum_line.encode("latin-1"))
             log.debug("checksum written byteCount=%d", byte_count)
@@ -309,6 +338,16 @@ class _TableEntry:
     lobs: dict[str, str] = field(default_factory=dict)
 
 
+def _shared_area(areas: list[str], default: str) -> str:
+    """The area all of areas are in, if there is one and it is not the
+    default; "" otherwise."""
+    if not areas or not areas[0] or areas[0].lower() == default.lower():
+        return ""
+    if any(a != areas[0] for a in areas[1:]):
+        return ""
+    return areas[0]
+
+
 def run_parse(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
     log.debug("parse started df=%s rules=%s output=%s", df_path, rules_path, output_path)
 
@@ -325,6 +364,13 @@ def run_parse(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
     table_order: list[str] = []  # lower-cased names, insertion order
     table_map: dict[str, _TableEntry] = {}
 
+    # Areas of all indexes and LOB fields per lower-cased table name, "" for
+    # a missing AREA/LOB-AREA line, to find tables whose indexes or LOBs all
+    # share one non-default area (Go: sharedArea in parse.go).
+    index_areas: dict[str, list[str]] = {}
+    lob_areas: dict[str, list[str]] = {}
+    lob_noted = False  # the current field has an entry in lob_areas
+
     def get_or_create(table_name: str) -> _TableEntry:
         key = table_name.lower()
         if key not in table_map:
@@ -348,12 +394,16 @@ def run_parse(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
             current_table = m.group(2)
             current_index = ""
             state = STATE_FIELD
+            lob_noted = (m.group(3) or "").lower() in ("blob", "clob")
+            if lob_noted:
+                lob_areas.setdefault(current_table.lower(), []).append("")
             log.debug("parsing FIELD field=%s table=%s", current_field, current_table)
         elif (m := RE_ADD_INDEX.match(line)) is not None:
             current_index = m.group(1)
             current_table = m.group(2)
             current_field = ""
             state = STATE_INDEX
+            index_areas.setdefault(current_table.lower(), []).append("")
             log.debug("parsing INDEX index=%s table=%s", current_index, current_table)
         elif RE_ADD_SEQUENCE.match(line):
             current_table = current_field = current_index = ""
@@ -373,6 +423,7 @@ def run_parse(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
             m = RE_AREA.match(line)
             if m:
                 area = m.group(2)
+                index_areas[current_table.lower()][-1] = area
                 if area.lower() != defaults.index.lower():
                     e = get_or_create(current_table)
                     e.indexes[current_index.lower()] = area
@@ -384,6 +435,11 @@ def run_parse(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
             m = RE_LOB_AREA.match(line)
             if m:
                 area = m.group(2)
+                if lob_noted:
+                    lob_areas[current_table.lower()][-1] = area
+                else:
+                    lob_areas.setdefault(current_table.lower(), []).append(area)
+                    lob_noted = True
                 if area.lower() != defaults.lob.lower():
                     e = get_or_create(current_table)
                     e.lobs[current_field] = area
@@ -395,21 +451,28 @@ def run_parse(df_path: str, rules_path: str, output_path: Optional[str]) -> int:
     # Mirror Go's models.go: Indexes/Lobs have no `omitempty` tag, so
     # yaml.v3 always emits them (as `{}` when empty) with map keys sorted
     # alphabetically (Go map marshalling is order-independent -> sorted).
+    # indexArea/lobArea are `omitempty` and replace the per-index/per-LOB
+    # entries when all of a table's indexes/LOBs share one non-default area.
     out_tables = []
     for key in table_order:
         e = table_map[key]
-        out_tables.append(
-            {
-                "name": e.name,
-                "area": e.area,
-                "indexes": dict(sorted(e.indexes.items())),
-                "lobs": dict(sorted(e.lobs.items())),
-            }
-        )
+        t = {"name": e.name, "area": e.area}
+        indexes, lobs = e.indexes, e.lobs
+        shared = _shared_area(index_areas.get(key, []), defaults.index)
+        if shared:
+            t["indexArea"] = shared
+            indexes = {}
+        shared = _shared_area(lob_areas.get(key, []), defaults.lob)
+        if shared:
+            t["lobArea"] = shared
+            lobs = {}
+        t["indexes"] = dict(sorted(indexes.items()))
+        t["lobs"] = dict(sorted(lobs.items()))
+        out_tables.append(t)
 
     out_doc = {
         "schemafixer": {
-            "version": _go_style_version(rules.version),
+            "version": _go_style_version(RULES_VERSION),
             "defaults": {
                 "table": defaults.table,
                 "index": defaults.index,
@@ -439,6 +502,7 @@ class AreaRecord:
     display_name: str
     key: str
     area: str
+    cls: str = ""  # Go: areaRecord.class ("word", "primary", "unique", "blob", "clob")
 
 
 @dataclass
@@ -447,6 +511,13 @@ class DiffRow:
     display_name: str
     source_area: str
     target_area: str
+    cls: str = ""
+
+    def construct(self) -> str:
+        """Go: DiffRow.Construct, e.g. "INDEX (word)"."""
+        if not self.cls:
+            return self.construct_type
+        return f"{self.construct_type} ({self.cls})"
 
 
 MISSING = "(not present)"
@@ -456,6 +527,16 @@ def extract_areas(lines: list[str]) -> list[AreaRecord]:
     records: list[AreaRecord] = []
     state = STATE_NONE
     current_table = current_field = current_index = ""
+    field_class = ""
+    index_flags: set[str] = set()
+    index_record: Optional[AreaRecord] = None
+
+    def index_class() -> str:
+        """Go: IndexInfo.Class."""
+        for flag in ("word", "primary", "unique"):
+            if flag in index_flags:
+                return flag
+        return ""
 
     for line in lines:
         m = RE_ADD_TABLE.match(line)
@@ -469,11 +550,15 @@ def extract_areas(lines: list[str]) -> list[AreaRecord]:
             current_table = m.group(2)
             current_index = ""
             state = STATE_FIELD
+            field_type = (m.group(3) or "").lower()
+            field_class = field_type if field_type in ("blob", "clob") else ""
         elif (m := RE_ADD_INDEX.match(line)) is not None:
             current_index = m.group(1)
             current_table = m.group(2)
             current_field = ""
             state = STATE_INDEX
+            index_flags = set()
+            index_record = None
         elif RE_ADD_SEQUENCE.match(line):
             current_table = current_field = current_index = ""
             state = STATE_OTHER
@@ -494,14 +579,19 @@ def extract_areas(lines: list[str]) -> list[AreaRecord]:
         elif state == STATE_INDEX:
             m = RE_AREA.match(line)
             if m:
-                records.append(
-                    AreaRecord(
-                        construct_type="INDEX",
-                        display_name=f"{current_table}.{current_index}",
-                        key=f"index:{current_table.lower()}.{current_index.lower()}",
-                        area=m.group(2),
-                    )
+                index_record = AreaRecord(
+                    construct_type="INDEX",
+                    display_name=f"{current_table}.{current_index}",
+                    key=f"index:{current_table.lower()}.{current_index.lower()}",
+                    area=m.group(2),
+                    cls=index_class(),
                 )
+                records.append(index_record)
+            elif (m := RE_INDEX_FLAG.match(line)) is not None:
+                # UNIQUE/PRIMARY/WORD follow the AREA line in a dump.
+                index_flags.add(m.group(1).lower())
+                if index_record is not None:
+                    index_record.cls = index_class()
         elif state == STATE_FIELD:
             m = RE_LOB_AREA.match(line)
             if m:
@@ -511,6 +601,7 @@ def extract_areas(lines: list[str]) -> list[AreaRecord]:
                         display_name=f"{current_table}.{current_field}",
                         key=f"lob:{current_table.lower()}.{current_field.lower()}",
                         area=m.group(2),
+                        cls=field_class,
                     )
                 )
 
@@ -531,7 +622,7 @@ def print_diff_table(w, rows: list[DiffRow]) -> None:
     w_source = len(h_source)
 
     for r in rows:
-        w_construct = max(w_construct, len(r.construct_type))
+        w_construct = max(w_construct, len(r.construct()))
         w_name = max(w_name, len(r.display_name))
         w_source = max(w_source, len(r.source_area))
 
@@ -552,7 +643,7 @@ def print_diff_table(w, rows: list[DiffRow]) -> None:
         )
     )
     for r in rows:
-        w.write(fmt_row(r.construct_type, r.display_name, r.source_area, r.target_area))
+        w.write(fmt_row(r.construct(), r.display_name, r.source_area, r.target_area))
 
 
 def print_proutil_commands(
@@ -675,14 +766,14 @@ def run_diff(
         seen_keys.add(rec.key)
         tgt = target_map.get(rec.key)
         if tgt is None:
-            rows.append(DiffRow(rec.construct_type, rec.display_name, rec.area, MISSING))
+            rows.append(DiffRow(rec.construct_type, rec.display_name, rec.area, MISSING, rec.cls))
             continue
         if rec.area.lower() != tgt.area.lower():
-            rows.append(DiffRow(rec.construct_type, rec.display_name, rec.area, tgt.area))
+            rows.append(DiffRow(rec.construct_type, rec.display_name, rec.area, tgt.area, tgt.cls))
 
     for rec in target_records:
         if rec.key not in seen_keys:
-            rows.append(DiffRow(rec.construct_type, rec.display_name, MISSING, rec.area))
+            rows.append(DiffRow(rec.construct_type, rec.display_name, MISSING, rec.area, rec.cls))
 
     if not rows:
         return 0
@@ -740,6 +831,14 @@ def flatten_file(src_path: str, dest_path: str) -> tuple[int, int, int]:
     if line_ending != "\n":
         new_content = new_content.replace("\n", line_ending)
 
+    # Regenerate the trailing byte count so it matches the new content.
+    body, sep, last = new_content.rstrip(line_ending).rpartition(line_ending)
+    if sep and RE_CHECKSUM.match(last):
+        body += sep
+        byte_count = trailer_offset(body, line_ending)
+        new_content = f"{body}{byte_count:010d}{line_ending}"
+        log.debug("checksum written byteCount=%d", byte_count)
+
     with open(dest_path, "w", encoding="latin-1", newline="") as f:
         f.write(new_content)
 
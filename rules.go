@@ -0,0 +1,597 @@
+package schemafixer
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// RulesFile is the top-level structure of the rules YAML.
+type RulesFile struct {
+	SchemaFixer SchemaFixerRules `yaml:"schemafixer"`
+}
+
+// SchemaFixerRules contains the full fixer configuration.
+type SchemaFixerRules struct {
+	Version  float64      `yaml:"version"`
+	Defaults AreaDefaults `yaml:"defaults"`
+	Tables   []TableRule  `yaml:"tables"`
+	Rules    []AreaRule   `yaml:"rules,omitempty"`
+	Groups   []Group      `yaml:"groups,omitempty"`
+
+	// AreaMap renames areas one to one; MapOrder, MapFirst or MapLast,
+	// says whether it applies before or after the other rules.
+	AreaMap  map[string]string `yaml:"areaMap,omitempty"`
+	MapOrder string            `yaml:"mapOrder,omitempty"`
+
+	// Areas, when not empty, lists every area of the target database;
+	// Apply reports areas outside it.
+	Areas []string `yaml:"areas,omitempty"`
+
+	// Environments are overlays selected by name, see Environment.
+	Environments map[string]Environment `yaml:"environments,omitempty"`
+
+	// Extends and Include name the files this one is composed with; they
+	// are resolved, and cleared, by ReadRules and LoadRules.
+	Extends string   `yaml:"extends,omitempty"`
+	Include []string `yaml:"include,omitempty"`
+
+	// Vars are the values of ${NAME} in area templates. Names not in Vars
+	// are looked up in the process environment.
+	Vars map[string]string `yaml:"-"`
+
+	pos map[string]source // where top-level keys, defaults and includes are defined
+}
+
+// AreaDefaults holds the fallback area names used when no explicit rule matches.
+// The per-kind defaults, such as index.word, are optional and fall back to
+// Index or Lob when empty.
+type AreaDefaults struct {
+	Table string `yaml:"table"`
+	Index string `yaml:"index"`
+	Lob   string `yaml:"lob"`
+
+	IndexWord    string `yaml:"index.word,omitempty"`
+	IndexPrimary string `yaml:"index.primary,omitempty"`
+	IndexUnique  string `yaml:"index.unique,omitempty"`
+	LobBlob      string `yaml:"lob.blob,omitempty"`
+	LobClob      string `yaml:"lob.clob,omitempty"`
+}
+
+// IndexFor returns the default area for ix: the default of the first of
+// word, primary and unique that applies to ix and is set, else Index.
+func (d AreaDefaults) IndexFor(ix *IndexInfo) string {
+	_, area := d.indexDefault(ix)
+	return area
+}
+
+// LobFor returns the default area for the LOB field f: lob.blob or
+// lob.clob if set, else Lob.
+func (d AreaDefaults) LobFor(f *FieldInfo) string {
+	_, area := d.lobDefault(f)
+	return area
+}
+
+// indexDefault returns the key and area of the default IndexFor returns.
+func (d AreaDefaults) indexDefault(ix *IndexInfo) (key, area string) {
+	switch {
+	case ix.Word && d.IndexWord != "":
+		return "index.word", d.IndexWord
+	case ix.Primary && d.IndexPrimary != "":
+		return "index.primary", d.IndexPrimary
+	case ix.Unique && d.IndexUnique != "":
+		return "index.unique", d.IndexUnique
+	}
+	return "index", d.Index
+}
+
+// lobDefault returns the key and area of the default LobFor returns.
+func (d AreaDefaults) lobDefault(f *FieldInfo) (key, area string) {
+	switch {
+	case f.Class() == "blob" && d.LobBlob != "":
+		return "lob.blob", d.LobBlob
+	case f.Class() == "clob" && d.LobClob != "":
+		return "lob.clob", d.LobClob
+	}
+	return "lob", d.Lob
+}
+
+// TableRule holds per-table area overrides for the table itself, its indexes and its LOB fields.
+// Name and the keys of Indexes and Lobs may be globs or /regexes/. IndexArea
+// and LobArea apply to every index and LOB field of the table that has no
+// entry in Indexes or Lobs.
+type TableRule struct {
+	Name      string            `yaml:"name"`
+	Area      string            `yaml:"area"`
+	IndexArea string            `yaml:"indexArea,omitempty"`
+	LobArea   string            `yaml:"lobArea,omitempty"`
+	Indexes   map[string]string `yaml:"indexes"`
+	Lobs      map[string]string `yaml:"lobs"`
+
+	src source            // the entry
+	pos map[string]source // "area", "indexArea", "lobArea", "indexes.<key>" and "lobs.<key>"
+}
+
+// AreaRule assigns Area to the tables, indexes or LOB fields for which the
+// condition When holds, e.g. "table.hasLobs && table.fieldCount > 50". A rule
+// that refers to index attributes places indexes, one that refers to field
+// attributes places LOB fields, and one that refers only to table attributes
+// places tables.
+//
+// A rule with From moves the constructs currently in that area to To
+// instead. Without When it applies to tables, indexes and LOB fields alike;
+// with When, only to the constructs the condition places.
+type AreaRule struct {
+	When string `yaml:"when,omitempty"`
+	Area string `yaml:"area,omitempty"`
+	From string `yaml:"from,omitempty"`
+	To   string `yaml:"to,omitempty"`
+
+	src  source
+	cond *expr // When, compiled by validateRules
+}
+
+// Keep is the area that leaves a construct in the area it is in. It may be
+// used wherever the rules name an area.
+const Keep = "@keep"
+
+// schemaArea is the area of a construct without an AREA or LOB-AREA line.
+const schemaArea = FlattenArea
+
+// target returns the area rule assigns.
+func (rule *AreaRule) target() string {
+	if rule.From != "" {
+		return rule.To
+	}
+	return rule.Area
+}
+
+// ReadRules decodes a rules file from r. The files it extends and includes
+// are resolved against the current directory.
+func ReadRules(r io.Reader) (*RulesFile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	name := inputName(r)
+	if name == "" {
+		name = "<rules>"
+	}
+	rules, err := new(loader).read(data, name, ".")
+	if err != nil {
+		return nil, err
+	}
+	return &RulesFile{SchemaFixer: *rules}, nil
+}
+
+// LoadRules reads and decodes the rules file at path, composed with the
+// files it extends and includes.
+func LoadRules(path string) (*RulesFile, error) {
+	rules, err := new(loader).load(path, source{})
+	if err != nil {
+		return nil, err
+	}
+	return &RulesFile{SchemaFixer: *rules}, nil
+}
+
+// validate checks the patterns, conditions, area templates, area map and
+// areas catalog of r and its environments.
+func (r *SchemaFixerRules) validate() error {
+	if err := r.validatePatterns(); err != nil {
+		return err
+	}
+	if err := r.validateGroups(); err != nil {
+		return err
+	}
+	if err := r.validateRules(); err != nil {
+		return err
+	}
+	if err := r.validateTemplates(); err != nil {
+		return err
+	}
+	if err := r.validateAreaMap(); err != nil {
+		return err
+	}
+	if err := validateAreas(r.Areas); err != nil {
+		return err
+	}
+	return r.validateEnvironments()
+}
+
+// validateRules checks that every conditional rule has a valid condition
+// and an area: a when clause with an area, or a from area with a to area.
+// The conditions are compiled once, here.
+func (r *SchemaFixerRules) validateRules() error {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.From != "" {
+			if rule.To == "" {
+				return fmt.Errorf("rules[%d]: from %q without to", i, rule.From)
+			}
+			if rule.Area != "" {
+				return fmt.Errorf("rules[%d]: from %q with area; use to", i, rule.From)
+			}
+			if rule.When == "" {
+				continue
+			}
+		} else if rule.To != "" {
+			return fmt.Errorf("rules[%d]: to %q without from", i, rule.To)
+		}
+		cond, err := compileExpr(rule.When)
+		if err != nil {
+			return fmt.Errorf("rules[%d]: when %q: %w", i, rule.When, err)
+		}
+		rule.cond = cond
+		if rule.From == "" && rule.Area == "" {
+			return fmt.Errorf("rules[%d]: no area", i)
+		}
+	}
+	return nil
+}
+
+// conditions returns r with the condition of every conditional rule
+// compiled. ReadRules compiles them; the conditions of rules built in Go
+// are compiled into a copy, so r itself is not modified.
+func (r *SchemaFixerRules) conditions() (*SchemaFixerRules, error) {
+	if !slices.ContainsFunc(r.Rules, func(rule AreaRule) bool { return rule.When != "" && rule.cond == nil }) {
+		return r, nil
+	}
+	c := *r
+	c.Rules = slices.Clone(r.Rules)
+	if err := c.validateRules(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ── Rules lookup ──────────────────────────────────────────────────────────────
+//
+// Table names and the keys of indexes and lobs are patterns: an exact name,
+// a /regex/ or a glob (see MatchKind). When several rules match, exact names
+// beat regexes and regexes beat globs. For an index or LOB field, the best
+// matching table rule with an entry for it or an indexArea or lobArea
+// decides. Next comes the group the table belongs to (see Group). A
+// construct neither places is placed by the first conditional rule of its
+// kind whose condition holds, and otherwise by the default.
+
+// TableInfo describes a table for conditional rules.
+type TableInfo struct {
+	Name     string
+	DumpName string
+	Area     string // current area, "Schema Area" without an AREA line
+	Fields   int    // fields added to the table
+	Indexes  int    // indexes added to the table
+	Lobs     int    // BLOB and CLOB fields among Fields
+}
+
+// IndexInfo describes an index for conditional rules.
+type IndexInfo struct {
+	Table   string
+	Name    string
+	Area    string // current area, "Schema Area" without an AREA line
+	Unique  bool
+	Primary bool
+	Word    bool
+	Fields  int // number of INDEX-FIELD lines
+}
+
+// Class returns the most specific kind of index ix is, as used by the
+// per-kind defaults and shown by Diff: "word", "primary", "unique" or "".
+func (ix *IndexInfo) Class() string {
+	switch {
+	case ix.Word:
+		return "word"
+	case ix.Primary:
+		return "primary"
+	case ix.Unique:
+		return "unique"
+	}
+	return ""
+}
+
+// FieldInfo describes a LOB field for conditional rules.
+type FieldInfo struct {
+	Table   string
+	Name    string
+	Type    string // data type, e.g. "clob"
+	LobSize int64  // LOB-BYTES, or LOB-SIZE in bytes
+	LobArea string // current area, "Schema Area" without a LOB-AREA line
+}
+
+// Class returns "blob" or "clob" for a LOB field, "" for a field of another
+// type that has a LOB-AREA.
+func (f *FieldInfo) Class() string {
+	switch f.Type {
+	case "blob", "clob":
+		return f.Type
+	}
+	return ""
+}
+
+// TableArea returns the area for a table by name, falling back to the
+// default. Conditional rules are not consulted; use TableAreaFor. An area
+// template that cannot be expanded is returned as written.
+func (r *SchemaFixerRules) TableArea(tableName string) string {
+	d, ok := r.namedTableArea(tableName, nil)
+	if !ok {
+		d = r.defaultArea("table", r.Defaults.Table)
+	}
+	return r.expandOrKeep(d, areaData{Table: tableName})
+}
+
+// IndexArea returns the area for a specific index on a table by name,
+// falling back to the global default. Conditional rules are not consulted;
+// use IndexAreaFor. An area template that cannot be expanded is returned as
+// written.
+func (r *SchemaFixerRules) IndexArea(tableName, indexName string) string {
+	d, ok := r.namedIndexArea(tableName, indexName, nil)
+	if !ok {
+		d = r.defaultArea("index", r.Defaults.Index)
+	}
+	return r.expandOrKeep(d, areaData{Table: tableName, Index: indexName})
+}
+
+// LobArea returns the LOB area for a specific field on a table by name,
+// falling back to the global default. Conditional rules are not consulted;
+// use LobAreaFor. An area template that cannot be expanded is returned as
+// written.
+func (r *SchemaFixerRules) LobArea(tableName, fieldName string) string {
+	d, ok := r.namedLobArea(tableName, fieldName, nil)
+	if !ok {
+		d = r.defaultArea("lob", r.Defaults.Lob)
+	}
+	return r.expandOrKeep(d, areaData{Table: tableName, Field: fieldName})
+}
+
+// TableAreaFor returns the area for table t: a table rule naming it, else
+// its group, else the first matching conditional rule, else the default.
+// The error reports an area template that cannot be expanded, or the
+// invalid condition of a rule built in Go.
+func (r *SchemaFixerRules) TableAreaFor(t *TableInfo) (string, error) {
+	r, err := r.conditions()
+	if err != nil {
+		return "", err
+	}
+	d, err := r.tableDecision(t, nil)
+	return d.area, err
+}
+
+// IndexAreaFor returns the area for index ix of table t: a table rule
+// naming it, else the table's group, else the first matching conditional
+// rule, else the default for its kind (see AreaDefaults.IndexFor). The
+// error reports an area template that cannot be expanded, or the invalid
+// condition of a rule built in Go.
+func (r *SchemaFixerRules) IndexAreaFor(t *TableInfo, ix *IndexInfo) (string, error) {
+	r, err := r.conditions()
+	if err != nil {
+		return "", err
+	}
+	d, err := r.indexDecision(t, ix, nil)
+	return d.area, err
+}
+
+// LobAreaFor returns the LOB area for field f of table t: a table rule
+// naming it, else the table's group, else the first matching conditional
+// rule, else the default for its type (see AreaDefaults.LobFor). The error
+// reports an area template that cannot be expanded, or the invalid
+// condition of a rule built in Go.
+func (r *SchemaFixerRules) LobAreaFor(t *TableInfo, f *FieldInfo) (string, error) {
+	r, err := r.conditions()
+	if err != nil {
+		return "", err
+	}
+	d, err := r.lobDecision(t, f, nil)
+	return d.area, err
+}
+
+// tableDecision returns the expanded area TableAreaFor returns and where
+// the rule that chose it is defined. The rules considered are recorded in
+// tr, which may be nil.
+func (r *SchemaFixerRules) tableDecision(t *TableInfo, tr *Explanation) (decision, error) {
+	d, ok := r.namedTableArea(t.Name, tr)
+	if !ok {
+		d, ok = r.conditional(KindTable, env{table: t}, tr)
+	}
+	if !ok {
+		d = r.defaultArea("table", r.Defaults.Table)
+		tr.decide("defaults.table", d)
+	}
+	area, err := r.expand(d, KindTable, t.Name, areaData{Table: t.Name, DumpName: t.DumpName})
+	return decision{area, d.at}, err
+}
+
+// indexDecision is tableDecision for IndexAreaFor.
+func (r *SchemaFixerRules) indexDecision(t *TableInfo, ix *IndexInfo, tr *Explanation) (decision, error) {
+	d, ok := r.namedIndexArea(ix.Table, ix.Name, tr)
+	if !ok {
+		d, ok = r.conditional(KindIndex, env{table: t, index: ix}, tr)
+	}
+	if !ok {
+		key, area := r.Defaults.indexDefault(ix)
+		d = r.defaultArea(key, area)
+		tr.decide("defaults."+key, d)
+	}
+	area, err := r.expand(d, KindIndex, ix.Table+"."+ix.Name, areaData{Table: ix.Table, DumpName: t.DumpName, Index: ix.Name})
+	return decision{area, d.at}, err
+}
+
+// lobDecision is tableDecision for LobAreaFor.
+func (r *SchemaFixerRules) lobDecision(t *TableInfo, f *FieldInfo, tr *Explanation) (decision, error) {
+	d, ok := r.namedLobArea(f.Table, f.Name, tr)
+	if !ok {
+		d, ok = r.conditional(KindLob, env{table: t, field: f}, tr)
+	}
+	if !ok {
+		key, area := r.Defaults.lobDefault(f)
+		d = r.defaultArea(key, area)
+		tr.decide("defaults."+key, d)
+	}
+	area, err := r.expand(d, KindLob, f.Table+"."+f.Name, areaData{Table: f.Table, DumpName: t.DumpName, Field: f.Name})
+	return decision{area, d.at}, err
+}
+
+// declared reports whether area is in the areas catalog, or there is no
+// catalog.
+func (r *SchemaFixerRules) declared(area string) bool {
+	return len(r.Areas) == 0 || slices.ContainsFunc(r.Areas, func(a string) bool { return strings.EqualFold(a, area) })
+}
+
+// validateAreas checks that the areas of a catalog are not empty and differ
+// in more than case.
+func validateAreas(areas []string) error {
+	for i, a := range areas {
+		if strings.TrimSpace(a) == "" {
+			return fmt.Errorf("areas[%d]: empty area", i)
+		}
+		if j := slices.IndexFunc(areas[:i], func(o string) bool { return strings.EqualFold(o, a) }); j >= 0 {
+			return fmt.Errorf("areas: %q and %q are the same area", areas[j], a)
+		}
+	}
+	return nil
+}
+
+// checkArea returns why the area of d cannot be loaded, or "", and how
+// severe that is: an empty area is an error, an area that is not in the
+// areas catalog a warning.
+func (r *SchemaFixerRules) checkArea(d decision) (Severity, string) {
+	from := ""
+	if d.at.line > 0 {
+		from = " from " + d.at.String()
+	}
+	switch {
+	case strings.TrimSpace(d.area) == "":
+		return SeverityError, "empty area" + from
+	case !r.declared(d.area):
+		return SeverityWarning, fmt.Sprintf("area %q%s is not declared in areas%s", d.area, from, suggest(d.area, r.Areas))
+	}
+	return 0, ""
+}
+
+// defaultArea returns the decision for the default area of key, e.g.
+// "index.word". An unset default is attributed to the defaults section.
+func (r *SchemaFixerRules) defaultArea(key, area string) decision {
+	at, ok := r.pos["defaults."+key]
+	if !ok {
+		at = r.pos["defaults"]
+	}
+	return decision{area, at}
+}
+
+// expandOrKeep expands the area of d, or returns it as written if that
+// fails.
+func (r *SchemaFixerRules) expandOrKeep(d decision, data areaData) string {
+	if area, err := expandArea(d.area, data, r.Vars); err == nil {
+		return area
+	}
+	return d.area
+}
+
+func (r *SchemaFixerRules) namedTableArea(tableName string, tr *Explanation) (decision, bool) {
+	for _, m := range r.tableRules(tableName) {
+		if m.rule.Area != "" {
+			return tr.decide(m.path()+" area", decision{m.rule.Area, m.rule.at("area")}), true
+		}
+		tr.skip(m.path(), m.rule.src, "", "no area")
+	}
+	return r.groupArea(tableName, "area", func(g *Group) string { return g.Area }, tr)
+}
+
+func (r *SchemaFixerRules) namedIndexArea(tableName, indexName string, tr *Explanation) (decision, bool) {
+	for _, m := range r.tableRules(tableName) {
+		if key, area, mk := lookup(m.rule.Indexes, indexName); mk != NoMatch {
+			tr.shadowed(m, "indexes", key, indexName)
+			return tr.decide(m.path()+" indexes."+key, decision{area, m.rule.at("indexes." + key)}), true
+		}
+		if m.rule.IndexArea != "" {
+			return tr.decide(m.path()+" indexArea", decision{m.rule.IndexArea, m.rule.at("indexArea")}), true
+		}
+		tr.skip(m.path(), m.rule.src, "", "no entry in indexes and no indexArea")
+	}
+	return r.groupArea(tableName, "indexArea", func(g *Group) string { return g.IndexArea }, tr)
+}
+
+func (r *SchemaFixerRules) namedLobArea(tableName, fieldName string, tr *Explanation) (decision, bool) {
+	for _, m := range r.tableRules(tableName) {
+		if key, area, mk := lookup(m.rule.Lobs, fieldName); mk != NoMatch {
+			tr.shadowed(m, "lobs", key, fieldName)
+			return tr.decide(m.path()+" lobs."+key, decision{area, m.rule.at("lobs." + key)}), true
+		}
+		if m.rule.LobArea != "" {
+			return tr.decide(m.path()+" lobArea", decision{m.rule.LobArea, m.rule.at("lobArea")}), true
+		}
+		tr.skip(m.path(), m.rule.src, "", "no entry in lobs and no lobArea")
+	}
+	return r.groupArea(tableName, "lobArea", func(g *Group) string { return g.LobArea }, tr)
+}
+
+// groupArea returns the area of the group of tableName that area returns,
+// key being its name in the rules.
+func (r *SchemaFixerRules) groupArea(tableName, key string, area func(*Group) string, tr *Explanation) (decision, bool) {
+	g, _ := r.Group(tableName)
+	tr.otherGroups(r, tableName, g)
+	if g == nil {
+		return decision{}, false
+	}
+	path := fmt.Sprintf("groups[%d] (%s)", slices.IndexFunc(r.Groups, func(o Group) bool { return o.Name == g.Name }), g.Name)
+	if area(g) == "" {
+		tr.skip(path, g.src, "", "no %s", key)
+		return decision{}, false
+	}
+	return tr.decide(path+" "+key, decision{area(g), g.at(key)}), true
+}
+
+// at returns where key of t is defined, or t itself if unknown.
+func (t *TableRule) at(key string) source {
+	if s, ok := t.pos[key]; ok {
+		return s
+	}
+	return t.src
+}
+
+// conditional returns the area of the first conditional rule of kind whose
+// condition holds in e and whose from area, if any, is the construct's
+// current area.
+func (r *SchemaFixerRules) conditional(kind Kind, e env, tr *Explanation) (decision, bool) {
+	for i, rule := range r.Rules {
+		path := fmt.Sprintf("rules[%d]", i)
+		if rule.From != "" && !strings.EqualFold(rule.From, e.area(kind)) {
+			tr.skip(path, rule.src, rule.target(), "from %q, but the area is %q", rule.From, e.area(kind))
+			continue
+		}
+		if x := rule.cond; x != nil {
+			switch {
+			case x.kind != kind:
+				tr.skip(path, rule.src, rule.target(), "when %q places %s", rule.When, kindPlural(x.kind))
+				continue
+			case !x.eval(e).b:
+				tr.skip(path, rule.src, rule.target(), "when %q is false", rule.When)
+				continue
+			}
+		}
+		return tr.decide(path, decision{rule.target(), rule.src}), true
+	}
+	return decision{}, false
+}
+
+// currentArea returns the area set by the AREA or LOB-AREA attribute a,
+// which may be nil.
+func currentArea(a *df.Attribute) string {
+	if a == nil {
+		return schemaArea
+	}
+	return a.Value()
+}
+
+// needsTableAggregates reports whether a conditional rule tests the fields
+// or indexes of a table, which are only known after its ADD TABLE.
+func (r *SchemaFixerRules) needsTableAggregates() bool {
+	for _, rule := range r.Rules {
+		if rule.cond != nil && rule.cond.aggregates {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRules_TableIndexAndLobArea(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    index: IndexArea
+    lob: LobArea
+  tables:
+    - name: order
+      indexArea: OrderIdx
+      lobArea: OrderLob
+      indexes:
+        ordernum: OrderNumIdx
+      lobs:
+        notes: NotesLob
+    - name: "*"
+      indexes:
+        custnum: AnyCustNum
+  rules:
+    - when: index.word
+      area: WordIdx
+`)
+	area := areaOf(t)
+	checks := []struct{ got, want string }{
+		{rules.IndexArea("order", "OrderNum"), "OrderNumIdx"},
+		{rules.IndexArea("order", "CustNum"), "OrderIdx"}, // the exact table rule decides
+		{area(rules.IndexAreaFor(&TableInfo{Name: "order"}, &IndexInfo{Table: "order", Name: "w", Word: true})), "OrderIdx"},
+		{rules.IndexArea("customer", "CustNum"), "AnyCustNum"},
+		{rules.IndexArea("customer", "Name"), "IndexArea"},
+		{rules.LobArea("order", "Notes"), "NotesLob"},
+		{rules.LobArea("order", "Image"), "OrderLob"},
+		{rules.LobArea("item", "Image"), "LobArea"},
+	}
+	for i, c := range checks {
+		if c.got != c.want {
+			t.Errorf("check %d: got %q, want %q", i, c.got, c.want)
+		}
+	}
+}
+
+func TestApply_KindDefaults(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: DataArea
+    index: IndexArea
+    lob: LobArea
+    index.word: WordIdx
+    index.unique: UniqueIdx
+    lob.clob: ClobArea
+  tables:
+    - name: order
+      indexes:
+        notesword: NamedIdx
+`)
+	src := "ADD TABLE \"Order\"\n  AREA \"Schema Area\"\n\n" +
+		"ADD FIELD \"Notes\" OF \"Order\" AS clob \n  LOB-AREA \"Schema Area\"\n\n" +
+		"ADD FIELD \"Image\" OF \"Order\" AS blob \n  LOB-AREA \"Schema Area\"\n\n" +
+		"ADD INDEX \"OrderNum\" ON \"Order\" \n  AREA \"Schema Area\"\n  UNIQUE\n  PRIMARY\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Order\" \n  AREA \"Schema Area\"\n\n" +
+		"ADD INDEX \"Comments\" ON \"Order\" \n  AREA \"Schema Area\"\n  WORD\n\n" +
+		"ADD INDEX \"NotesWord\" ON \"Order\" \n  AREA \"Schema Area\"\n  WORD\n\n"
+
+	got, out := applyDF(t, src, rules, Options{})
+	want := strings.Join([]string{
+		"Order=DataArea",
+		"Order.Notes=ClobArea",
+		"Order.Image=LobArea",      // lob.blob is unset
+		"Order.OrderNum=UniqueIdx", // index.primary is unset, so unique applies
+		"Order.CustNum=IndexArea",
+		"Order.Comments=WordIdx",
+		"Order.NotesWord=NamedIdx", // an explicit entry beats the per-kind default
+	}, " ")
+	if got != want {
+		t.Errorf("changes:\n%s\nwant:\n%s", got, want)
+	}
+
+	// parse leaves out areas that equal their per-kind default.
+	rf, err := ParseRules(context.Background(), strings.NewReader(out), rules, Options{})
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	if tables := rf.SchemaFixer.Tables; len(tables) != 1 || fmt.Sprint(tables[0].Indexes) != "map[notesword:NamedIdx]" || len(tables[0].Lobs) != 0 {
+		t.Errorf("ParseRules() tables = %+v", tables)
+	}
+
+	// diff shows the class of each index and LOB.
+	d, err := Diff(context.Background(), strings.NewReader(src), strings.NewReader(out), Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	var constructs []string
+	for _, r := range d.Rows {
+		constructs = append(constructs, r.Construct())
+	}
+	if got, want := strings.Join(constructs, ","), "TABLE,LOB (clob),LOB (blob),INDEX (primary),INDEX,INDEX (word),INDEX (word)"; got != want {
+		t.Errorf("constructs = %s, want %s", got, want)
+	}
+}
+
+func TestApply_FromRulesAndKeep(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: "@keep"
+    index: "@keep"
+    lob: LobArea
+  tables:
+    - name: item
+      area: "@keep"
+  rules:
+    - from: Old Index Area
+      when: index.word
+      to: WordIdx
+    - from: Schema Area
+      to: DataArea
+    - when: index.area == "Old Index Area"
+      area: NewIdx
+`)
+	src := "ADD TABLE \"Order\"\n  AREA \"Schema Area\"\n\n" +
+		"ADD TABLE \"Item\"\n\n" +
+		"ADD TABLE \"Bin\"\n  AREA \"Bins\"\n\n" +
+		"ADD INDEX \"OrderNum\" ON \"Order\" \n  AREA \"Old Index Area\"\n\n" +
+		"ADD INDEX \"Comments\" ON \"Order\" \n  AREA \"Old Index Area\"\n  WORD\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Order\" \n\n" +
+		"ADD INDEX \"BinNum\" ON \"Bin\" \n  AREA \"Bins\"\n\n" +
+		"ADD FIELD \"Notes\" OF \"Order\" AS clob \n  LOB-AREA \"Lobs\"\n\n"
+
+	// Item has no AREA, so it is in the Schema Area, but its entry keeps it
+	// there; Bin and Bin.BinNum are kept by the defaults.
+	if got, _ := applyDF(t, src, rules, Options{}); got != "Order=DataArea Order.OrderNum=NewIdx Order.Comments=WordIdx Order.CustNum=DataArea Order.Notes=LobArea" {
+		t.Errorf("changes = %s", got)
+	}
+	if got, _ := applyDF(t, src, rules, Options{OnlyFrom: []string{"schema area"}}); got != "Order=DataArea Order.CustNum=DataArea" {
+		t.Errorf("changes with OnlyFrom = %s", got)
+	}
+}
+
+func TestReadRules_InvalidFromRules(t *testing.T) {
+	for src, want := range map[string]string{
+		"schemafixer:\n  rules:\n    - from: A\n":                             `<rules>: rules[0]: from "A" without to`,
+		"schemafixer:\n  rules:\n    - from: A\n      area: B\n":              `<rules>: rules[0]: from "A" without to`,
+		"schemafixer:\n  rules:\n    - from: A\n      to: B\n      area: C\n": `<rules>: rules[0]: from "A" with area; use to`,
+		"schemafixer:\n  rules:\n    - to: B\n":                               `<rules>: rules[0]: to "B" without from`,
+		"schemafixer:\n  defaults:\n    table: \"@skip\"\n":                   `<rules>: defaults.table "@skip": unknown special area; only @keep is defined`,
+	} {
+		if _, err := ReadRules(strings.NewReader(src)); err == nil || err.Error() != want {
+			t.Errorf("ReadRules() error = %v, want %s", err, want)
+		}
+	}
+}
+
+func TestApply_AreasCatalog(t *testing.T) {
+	src := "ADD TABLE \"Order\"\n  AREA \"Data Area\"\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Order\" \n  AREA \"Index Area\"\n\n" +
+		"ADD FIELD \"Note\" OF \"Order\" AS clob \n  LOB-AREA \"Lob Area\"\n\n"
+	rules := mustRules(t, `schemafixer:
+  areas: [DataArea, IndexArea]
+  defaults:
+    table: DataArea
+  tables:
+    - name: order
+      indexArea: IndexAera
+`)
+	var diags []string
+	_, out := applyDF(t, src, rules, Options{Report: func(d Diagnostic) { diags = append(diags, d.String()) }})
+	want := []string{
+		`4:1: warning: index Order.CustNum: area "IndexAera" from <rules>:7 is not declared in areas; did you mean "IndexArea"?`,
+		`7:1: error: lob Order.Note: empty area from <rules>:3; its LOB-AREA is left as it is`,
+	}
+	if strings.Join(diags, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(diags, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(out, "  LOB-AREA \"Lob Area\"\n") {
+		t.Errorf("LOB-AREA of an empty area changed:\n%s", out)
+	}
+
+	for _, bad := range []string{"areas: [DataArea, \"\"]", "areas: [DataArea, dataarea]"} {
+		if _, err := ReadRules(strings.NewReader("schemafixer:\n  " + bad + "\n")); err == nil {
+			t.Errorf("ReadRules() accepted %s", bad)
+		}
+	}
+}
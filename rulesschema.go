@@ -0,0 +1,156 @@
+package schemafixer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ── Rules file schema ─────────────────────────────────────────────────────────
+//
+// The JSON Schema of rules files and the keys ReadRules accepts are both
+// derived from the yaml tags of RulesFile and the types it holds, so adding
+// a field to the model adds it to both.
+
+// RulesSchema returns the JSON Schema of rules files, indented. Editors that
+// support YAML schemas use it to validate and complete rules files.
+func RulesSchema() ([]byte, error) {
+	defs := map[string]any{}
+	root := schemaOf(reflect.TypeFor[RulesFile](), defs)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "schemafixer rules"
+	root["$defs"] = defs
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaOf returns the schema of t. Structs other than RulesFile are added
+// to defs under their type name and referred to.
+func schemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64, reflect.Float32:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), defs)}
+	case reflect.Struct:
+		if t == reflect.TypeFor[RulesFile]() {
+			return objectSchema(t, defs)
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // a placeholder against recursion
+			defs[t.Name()] = objectSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	panic(fmt.Sprintf("schemafixer: no schema for %s", t))
+}
+
+// objectSchema returns the schema of the struct t: an object with a
+// property for every yaml key and no others.
+func objectSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	for _, f := range yamlFields(t) {
+		props[f.key] = schemaOf(f.typ, defs)
+	}
+	if p, ok := props["mapOrder"].(map[string]any); ok && t == reflect.TypeFor[SchemaFixerRules]() {
+		p["enum"] = []string{MapFirst, MapLast}
+	}
+	return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+}
+
+// yamlField is a field of a struct as it appears in YAML.
+type yamlField struct {
+	key string
+	typ reflect.Type
+}
+
+// yamlFields returns the fields of the struct t that have a yaml key, in
+// declaration order.
+func yamlFields(t reflect.Type) []yamlField {
+	var out []yamlField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		out = append(out, yamlField{key, f.Type})
+	}
+	return out
+}
+
+// unknownField matches the error yaml.v3 reports for a key that has no
+// field when decoding with KnownFields.
+var unknownField = regexp.MustCompile(`^line (\d+): field (.+) not found in type (\S+)$`)
+
+// decodeError rewrites the errors of decoding the rules file name: each
+// unknown key is reported at its line, with the closest known key.
+func decodeError(name string, err error) error {
+	var te *yaml.TypeError
+	if !errors.As(err, &te) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	var errs []error
+	for _, msg := range te.Errors {
+		m := unknownField.FindStringSubmatch(msg)
+		if m == nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, msg))
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		errs = append(errs, fmt.Errorf("%s: unknown key %q%s", source{name, line}, m[2], suggest(m[2], knownKeys(m[3]))))
+	}
+	return errors.Join(errs...)
+}
+
+// knownKeys returns the yaml keys of the rules type called name, such as
+// "schemafixer.TableRule", or nil.
+func knownKeys(name string) []string {
+	t := findType(reflect.TypeFor[RulesFile](), name)
+	if t == nil {
+		return nil
+	}
+	var keys []string
+	for _, f := range yamlFields(t) {
+		keys = append(keys, f.key)
+	}
+	return keys
+}
+
+// findType returns the struct type called name that t is or holds, or nil.
+func findType(t reflect.Type, name string) reflect.Type {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return findType(t.Elem(), name)
+	case reflect.Struct:
+		if t.String() == name {
+			return t
+		}
+		for _, f := range yamlFields(t) {
+			if found := findType(f.typ, name); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package schemafixer
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadRules_UnknownKeys(t *testing.T) {
+	src := `schemafixer:
+  default:
+    table: DataArea
+  tables:
+    - name: customer
+      indexs:
+        CustNum: IndexArea
+  environments:
+    prod:
+      groups:
+        - name: hist
+          priority: 1
+          lobarea: LobArea
+`
+	want := `<rules>:2: unknown key "default"; did you mean "defaults"?
+<rules>:6: unknown key "indexs"; did you mean "indexes"?
+<rules>:13: unknown key "lobarea"; did you mean "lobArea"?`
+	if _, err := ReadRules(strings.NewReader(src)); err == nil || err.Error() != want {
+		t.Errorf("ReadRules() error = %v\nwant:\n%s", err, want)
+	}
+}
+
+func TestRulesSchema(t *testing.T) {
+	got, err := RulesSchema()
+	if err != nil {
+		t.Fatalf("RulesSchema() error = %v", err)
+	}
+	want, err := os.ReadFile("model/rules.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Error("model/rules.schema.json is out of date; regenerate it with: schemafixer rules schema -o model/rules.schema.json")
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Properties           map[string]any `json:"properties"`
+			AdditionalProperties bool           `json:"additionalProperties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(got, &schema); err != nil {
+		t.Fatalf("schema is not JSON: %v", err)
+	}
+	for _, key := range []string{"name", "area", "indexArea", "lobArea", "indexes", "lobs"} {
+		if _, ok := schema.Defs["TableRule"].Properties[key]; !ok {
+			t.Errorf("TableRule has no property %q", key)
+		}
+	}
+	if _, ok := schema.Defs["SchemaFixerRules"].Properties["vars"]; ok {
+		t.Error("SchemaFixerRules has the property vars, which is not read from rules files")
+	}
+}
@@ -0,0 +1,153 @@
+// Package schemafixer fixes the storage areas of tables, indexes and LOB
+// fields in OpenEdge .df schema files.
+//
+// It is the library behind the schemafixer command: Apply, ParseRules, Diff,
+// Flatten, Verify, ValidateRules, Explain and Transcode implement the
+// subcommands of the same names on readers and writers instead of files, and
+// return structured results instead of printing them.
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/bfv/schemafixer/df"
+	"github.com/rs/zerolog"
+	"golang.org/x/text/encoding"
+)
+
+// Options configures the functions of this package. The zero value is ready
+// to use.
+type Options struct {
+	// Logger receives debug messages. The zero value discards them.
+	Logger zerolog.Logger
+
+	// EOL, when non-empty, replaces the line terminator of every line
+	// written. When empty each line keeps the terminator it was read with,
+	// so an unchanged .df is written back byte-identical. A missing final
+	// newline is never added.
+	EOL string
+
+	// Codepage is the OpenEdge codepage of the .df input, e.g. "ISO8859-1".
+	// When empty it is taken from the cpstream setting of the trailer if the
+	// input is seekable, and UTF-8 is assumed otherwise.
+	Codepage string
+
+	// Report receives the diagnostics about the input: problems with file,
+	// line and column, and notes such as inserted AREA lines. When nil they
+	// are logged to Logger.
+	Report func(Diagnostic)
+
+	// OnlyFrom, when non-empty, limits Apply to the tables, indexes and LOB
+	// fields currently in one of these areas; all others keep their area.
+	// A construct without an AREA or LOB-AREA line is in the Schema Area.
+	OnlyFrom []string
+
+	// MapOnly makes Apply translate areas through the area map of the
+	// rules and nothing else: defaults, table entries, groups and
+	// conditional rules are ignored.
+	MapOnly bool
+}
+
+// Kind identifies the kind of construct an area belongs to.
+type Kind int
+
+const (
+	KindTable Kind = iota
+	KindIndex
+	KindLob
+)
+
+// String returns the construct name as used in diff output.
+func (k Kind) String() string {
+	switch k {
+	case KindTable:
+		return "TABLE"
+	case KindIndex:
+		return "INDEX"
+	case KindLob:
+		return "LOB"
+	}
+	return "UNKNOWN"
+}
+
+// codepage determines the codepage of the .df in, see Options.Codepage. It
+// returns the codepage name and its encoding; a nil encoding means the input
+// is UTF-8 and is used as read. A trailer naming an unknown codepage is
+// logged and treated as UTF-8.
+func codepage(in io.Reader, opts Options) (string, encoding.Encoding, error) {
+	if opts.Codepage != "" {
+		enc, ok := df.Codepage(opts.Codepage)
+		if !ok {
+			return "", nil, fmt.Errorf("unknown codepage %q", opts.Codepage)
+		}
+		return opts.Codepage, enc, nil
+	}
+
+	// Pipes implement io.Seeker on some platforms but fail to seek.
+	rs, ok := in.(io.ReadSeeker)
+	if !ok {
+		return "UTF-8", nil, nil
+	}
+	if _, err := rs.Seek(0, io.SeekCurrent); err != nil {
+		return "UTF-8", nil, nil
+	}
+	name, err := df.DetectCodepage(rs)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading df trailer: %w", err)
+	}
+	if name == "" {
+		return "UTF-8", nil, nil
+	}
+	enc, ok := df.Codepage(name)
+	if !ok {
+		opts.Logger.Warn().Str("codepage", name).Msg("unknown codepage, reading as UTF-8")
+		return name, nil, nil
+	}
+	return name, enc, nil
+}
+
+// decode reads the .df from in node by node and calls fn for each, stopping
+// early when ctx is cancelled. Only the current node is held in memory.
+// Lines are decoded from cp to UTF-8 unless cp is nil. Each node is checked
+// by chk, if not nil, before fn sees it.
+func decode(ctx context.Context, in io.Reader, cp encoding.Encoding, chk *checker, fn func(df.Node) error) error {
+	dec := df.NewDecoder(in)
+	dec.Encoding = cp
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := dec.Next()
+		if err == io.EOF {
+			if chk != nil {
+				chk.finish()
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading df: %w", err)
+		}
+		if chk != nil {
+			chk.check(n)
+		}
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+}
+
+// encodeTrailer writes the trailer with its byte count regenerated for the
+// content written before it, and stores the new count in byteCount. Every
+// function that writes a .df goes through here so the count is consistent.
+func encodeTrailer(enc *df.Encoder, t *df.Trailer, byteCount *int64, log zerolog.Logger) error {
+	if err := enc.EncodeTrailer(t); err != nil {
+		return err
+	}
+	if n, ok := t.ByteCount(); ok {
+		*byteCount = n
+		log.Debug().Int64("byteCount", n).Msg("checksum written")
+	}
+	return nil
+}
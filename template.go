@@ -0,0 +1,213 @@
+package schemafixer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ── Area templates ────────────────────────────────────────────────────────────
+//
+// Every area in the rules may be a template that is expanded for the
+// construct it places, e.g. "{{.Table}}_Data", "{{upper .DumpName}}Idx" or
+// "${SITE}_Lob". The {{...}} actions are those of text/template with the
+// fields of areaData and the functions upper and lower; ${NAME} is the
+// variable NAME from SchemaFixerRules.Vars or, failing that, the process
+// environment. Areas without {{ or ${ are used as they are.
+
+// areaData holds the names an area template can refer to. Index and Field
+// are empty for tables.
+type areaData struct {
+	Table    string
+	DumpName string
+	Index    string
+	Field    string
+}
+
+// decision is an area the rules chose for a construct and where the rule
+// that chose it is defined.
+type decision struct {
+	area string
+	at   source
+}
+
+// unknownVar is the error for a ${NAME} that is not defined.
+type unknownVar string
+
+func (e unknownVar) Error() string { return fmt.Sprintf("unknown variable %q", string(e)) }
+
+var (
+	templates sync.Map // string → *template.Template
+	varRef    = regexp.MustCompile(`\$\{([^{}]*)\}`)
+)
+
+// isTemplate reports whether area needs to be expanded.
+func isTemplate(area string) bool {
+	return strings.Contains(area, "{{") || strings.Contains(area, "${")
+}
+
+// quoteTemplate returns a template that expands to area as it is written,
+// or area itself if it is not a template: every "{" is written as the
+// action {{"{"}}, so neither {{ nor ${ remains.
+func quoteTemplate(area string) string {
+	if !isTemplate(area) {
+		return area
+	}
+	return strings.ReplaceAll(area, "{", `{{"{"}}`)
+}
+
+// compileTemplate parses an area template. ${NAME} is rewritten to a call of
+// the function var, which is bound to the variables when it is executed.
+func compileTemplate(area string) (*template.Template, error) {
+	if t, ok := templates.Load(area); ok {
+		return t.(*template.Template), nil
+	}
+	text := varRef.ReplaceAllStringFunc(area, func(ref string) string {
+		return fmt.Sprintf("{{var %q}}", ref[2:len(ref)-1])
+	})
+	t, err := template.New("area").Option("missingkey=error").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"var":   func(string) (string, error) { return "", nil },
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	templates.Store(area, t)
+	return t, nil
+}
+
+// checkTemplate reports an error if area is not a valid template, such as
+// one that uses an unknown field or function. Variables are not checked,
+// because they may be set after the rules are read.
+func checkTemplate(area string) error {
+	if !isTemplate(area) {
+		return nil
+	}
+	t, err := compileTemplate(area)
+	if err != nil {
+		return err
+	}
+	return t.Execute(new(bytes.Buffer), areaData{})
+}
+
+// expandArea expands area for data, looking up variables in vars and then
+// in the environment.
+func expandArea(area string, data areaData, vars map[string]string) (string, error) {
+	if !isTemplate(area) {
+		return area, nil
+	}
+	t, err := compileTemplate(area)
+	if err != nil {
+		return "", err
+	}
+	t, err = t.Clone()
+	if err != nil {
+		return "", err
+	}
+	t.Funcs(template.FuncMap{"var": func(name string) (string, error) {
+		if v, ok := vars[name]; ok {
+			return v, nil
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v, nil
+		}
+		return "", unknownVar(name)
+	}})
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		var uv unknownVar
+		if errors.As(err, &uv) {
+			return "", uv
+		}
+		return "", err
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return "", errors.New("expands to an empty area name")
+	}
+	return b.String(), nil
+}
+
+// expand expands the area of d for the construct kind name, described by
+// data. The error names the construct and the rule that chose the area.
+func (r *SchemaFixerRules) expand(d decision, kind Kind, name string, data areaData) (string, error) {
+	area, err := expandArea(d.area, data, r.Vars)
+	if err != nil {
+		at := ""
+		if d.at.line > 0 {
+			at = " (" + d.at.String() + ")"
+		}
+		return "", fmt.Errorf("%s %s: area %q%s: %w", strings.ToLower(kind.String()), name, d.area, at, err)
+	}
+	return area, nil
+}
+
+// validateTemplates checks the area templates of r.
+func (r *SchemaFixerRules) validateTemplates() error {
+	return r.layer().eachArea(func(path, area string) error {
+		if strings.HasPrefix(area, "@") && area != Keep {
+			return fmt.Errorf("%s %q: unknown special area; only %s is defined", path, area, Keep)
+		}
+		if err := checkTemplate(area); err != nil {
+			return fmt.Errorf("%s %q: %w", path, area, err)
+		}
+		return nil
+	})
+}
+
+// eachArea calls fn for every area of e with its path in the rules, e.g.
+// "tables[2] (order) indexArea", until fn returns an error.
+func (e Environment) eachArea(fn func(path, area string) error) error {
+	d := e.Defaults
+	for _, f := range defaultFields(&d, &d) {
+		if *f.dst != "" {
+			if err := fn(f.key, *f.dst); err != nil {
+				return err
+			}
+		}
+	}
+	for i, t := range e.Tables {
+		at := fmt.Sprintf("tables[%d] (%s) ", i, t.Name)
+		areas := []struct{ key, area string }{{"area", t.Area}, {"indexArea", t.IndexArea}, {"lobArea", t.LobArea}}
+		for _, k := range slices.Sorted(maps.Keys(t.Indexes)) {
+			areas = append(areas, struct{ key, area string }{"indexes." + k, t.Indexes[k]})
+		}
+		for _, k := range slices.Sorted(maps.Keys(t.Lobs)) {
+			areas = append(areas, struct{ key, area string }{"lobs." + k, t.Lobs[k]})
+		}
+		for _, a := range areas {
+			if a.area != "" {
+				if err := fn(at+a.key, a.area); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i, g := range e.Groups {
+		at := fmt.Sprintf("groups[%d] (%s) ", i, g.Name)
+		for _, a := range []struct{ key, area string }{{"area", g.Area}, {"indexArea", g.IndexArea}, {"lobArea", g.LobArea}} {
+			if a.area != "" {
+				if err := fn(at+a.key, a.area); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i, rule := range e.Rules {
+		key := "area"
+		if rule.From != "" {
+			key = "to"
+		}
+		if err := fn(fmt.Sprintf("rules[%d] %s", i, key), rule.target()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
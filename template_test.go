@@ -0,0 +1,67 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestApply_AreaTemplates(t *testing.T) {
+	t.Setenv("SF_TEST_SITE", "EU")
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: "{{.Table}}_Data"
+    index: "{{upper .DumpName}}"
+    lob: "${SF_TEST_SITE}_Lob"
+  tables:
+    - name: order
+      indexes:
+        custnum: "${ZONE}_{{lower .Index}}"
+`)
+	rules.Vars = map[string]string{"ZONE": "Z1"}
+	src := "ADD TABLE \"Order\"\n  AREA \"Schema Area\"\n  DUMP-NAME \"order\"\n\n" +
+		"ADD FIELD \"Notes\" OF \"Order\" AS clob \n  LOB-AREA \"Schema Area\"\n\n" +
+		"ADD INDEX \"OrderNum\" ON \"Order\" \n  AREA \"Schema Area\"\n\n" +
+		"ADD INDEX \"CustNum\" ON \"Order\" \n  AREA \"Schema Area\"\n\n"
+
+	got, out := applyDF(t, src, rules, Options{})
+	if want := "Order=Order_Data Order.Notes=EU_Lob Order.OrderNum=ORDER Order.CustNum=Z1_custnum"; got != want {
+		t.Errorf("changes = %s, want %s", got, want)
+	}
+
+	// parse compares with the expanded defaults.
+	rf, err := ParseRules(context.Background(), strings.NewReader(out), rules, Options{})
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	if tables := rf.SchemaFixer.Tables; len(tables) != 1 || fmt.Sprint(tables[0].Indexes) != "map[custnum:Z1_custnum]" {
+		t.Errorf("ParseRules() tables = %+v", tables)
+	}
+
+	// An unknown variable or an empty name fails with the construct and
+	// the rule.
+	rules.Vars = nil
+	_, err = Apply(context.Background(), strings.NewReader(src), rules, io.Discard, Options{})
+	if want := `index Order.CustNum: area "${ZONE}_{{lower .Index}}" (<rules>:9): unknown variable "ZONE"`; err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %s", err, want)
+	}
+	src = strings.Replace(src, "  DUMP-NAME \"order\"\n", "", 1)
+	rules.Vars = map[string]string{"ZONE": "Z1"}
+	_, err = Apply(context.Background(), strings.NewReader(src), rules, io.Discard, Options{})
+	if want := `index Order.OrderNum: area "{{upper .DumpName}}" (<rules>:4): expands to an empty area name`; err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %s", err, want)
+	}
+}
+
+func TestReadRules_InvalidTemplate(t *testing.T) {
+	for src, want := range map[string]string{
+		"schemafixer:\n  defaults:\n    table: \"{{.Tabel}}\"\n":                    `<rules>: defaults.table "{{.Tabel}}": template: area:1:2: executing "area" at <.Tabel>: can't evaluate field Tabel in type schemafixer.areaData`,
+		"schemafixer:\n  tables:\n    - name: x\n      area: \"{{uper .Table}}\"\n": `<rules>: tables[0] (x) area "{{uper .Table}}": template: area:1: function "uper" not defined`,
+	} {
+		if _, err := ReadRules(strings.NewReader(src)); err == nil || err.Error() != want {
+			t.Errorf("ReadRules() error = %v, want %s", err, want)
+		}
+	}
+}
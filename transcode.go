@@ -0,0 +1,65 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// TranscodeResult describes what Transcode did.
+type TranscodeResult struct {
+	From string // codepage the input was read in
+	To   string // codepage the output was written in
+
+	// ByteCount is the regenerated trailer byte count, or -1 if the input
+	// had none.
+	ByteCount int64
+}
+
+// Transcode reads a .df from in, converts it to the OpenEdge codepage to and
+// writes the result to out. The cpstream setting of the trailer is updated
+// and the byte count regenerated. Characters that cannot be represented in
+// the target codepage are an error.
+func Transcode(ctx context.Context, in io.Reader, out io.Writer, to string, opts Options) (*TranscodeResult, error) {
+	log := opts.Logger
+	target, ok := df.Codepage(to)
+	if !ok {
+		return nil, fmt.Errorf("unknown codepage %q", to)
+	}
+	from, cp, err := codepage(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	res := &TranscodeResult{From: from, To: strings.ToUpper(to), ByteCount: -1}
+	log.Debug().Str("from", res.From).Str("to", res.To).Msg("transcode started")
+
+	enc := df.NewEncoder(out)
+	enc.EOL = opts.EOL
+	enc.Encoding = target
+
+	trailer := false
+	err = decode(ctx, in, cp, newChecker(in, opts), func(n df.Node) error {
+		if t, ok := n.(*df.Trailer); ok {
+			trailer = true
+			t.Set("cpstream", res.To)
+			return encodeTrailer(enc, t, &res.ByteCount, log)
+		}
+		return enc.Encode(n)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !trailer {
+		report(opts, Diagnostic{
+			Severity: SeverityWarning,
+			File:     inputName(in),
+			Msg:      fmt.Sprintf("no trailer, the output does not declare that it is %s", res.To),
+		})
+	}
+
+	log.Debug().Msg("transcode complete")
+	return res, nil
+}
@@ -0,0 +1,33 @@
+package schemafixer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTranscode(t *testing.T) {
+	src := strings.Replace(testDF, "\"Item\"", "\"Gr\xf6\xdfe\"", 1)
+	src = strings.Replace(src, "UTF-8", "ISO8859-1", 1)
+
+	var out bytes.Buffer
+	res, err := Transcode(context.Background(), strings.NewReader(src), &out, "utf-8", Options{})
+	if err != nil {
+		t.Fatalf("Transcode() error = %v", err)
+	}
+	if res.From != "ISO8859-1" || res.To != "UTF-8" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	body := strings.Replace(testDF, "\"Item\"", "\"Größe\"", 1)
+	body = strings.TrimSuffix(body, "0000000000\n")
+	if want := body + fmt.Sprintf("%010d\n", trailerOffset(body)); out.String() != want {
+		t.Errorf("Transcode() output mismatch\ngot:  %q\nwant: %q", out.String(), want)
+	}
+
+	if _, err := Transcode(context.Background(), strings.NewReader(src), &out, "EBCDIC", Options{}); err == nil {
+		t.Error("expected an error for an unknown codepage")
+	}
+}
@@ -0,0 +1,288 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// ValidateRulesResult lists the problems ValidateRules found.
+type ValidateRulesResult struct {
+	// Problems lists the diagnostics found: those about the rules, located
+	// in the rules files, followed by those about the .df. They are not
+	// passed to Options.Report.
+	Problems []Diagnostic
+}
+
+// OK reports whether no errors were found. Warnings do not count.
+func (v *ValidateRulesResult) OK() bool {
+	return !slices.ContainsFunc(v.Problems, func(p Diagnostic) bool { return p.Severity == SeverityError })
+}
+
+// ValidateRules checks rules against the schema of the .df read from in.
+// Errors are reported for table, index and LOB field names in the rules that
+// the schema doesn't have, with a suggestion if a name is close, lobs
+// entries for fields that are not LOBs, tables with more than one entry and
+// empty default areas. Patterns that match nothing are reported as
+// warnings.
+func ValidateRules(ctx context.Context, in io.Reader, rules *SchemaFixerRules, opts Options) (*ValidateRulesResult, error) {
+	res := &ValidateRulesResult{}
+	var dfProblems []Diagnostic
+	opts.Report = func(d Diagnostic) { dfProblems = append(dfProblems, d) }
+
+	_, cp, err := codepage(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &schema{byName: map[string]*schemaTable{}}
+	err = decode(ctx, in, cp, newChecker(in, opts), func(n df.Node) error {
+		s.add(n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	problem := func(sev Severity, at source, format string, args ...any) {
+		d := Diagnostic{Severity: sev, File: at.file, Msg: fmt.Sprintf(format, args...)}
+		if at.line > 0 {
+			d.Pos = df.Pos{Line: at.line, Col: 1}
+		}
+		res.Problems = append(res.Problems, d)
+	}
+
+	for _, d := range []struct{ key, area string }{
+		{"table", rules.Defaults.Table},
+		{"index", rules.Defaults.Index},
+		{"lob", rules.Defaults.Lob},
+	} {
+		if d.area == "" {
+			at, ok := rules.pos["defaults."+d.key]
+			if !ok {
+				at = rules.pos["defaults"]
+			}
+			problem(SeverityError, at, "the default %s area is empty", d.key)
+		}
+	}
+
+	for i, t := range rules.Tables {
+		if j := slices.IndexFunc(rules.Tables[:i], func(o TableRule) bool { return strings.EqualFold(o.Name, t.Name) }); j >= 0 {
+			problem(SeverityError, t.src, "table %q has another entry at %s, which takes precedence", t.Name, rules.Tables[j].src)
+			continue
+		}
+		tables := s.tables(t.Name)
+		switch {
+		case len(tables) > 0:
+		case kindOf(t.Name) == MatchExact:
+			problem(SeverityError, t.src, "unknown table %q%s", t.Name, suggest(t.Name, s.tableNames()))
+			continue
+		default:
+			problem(SeverityWarning, t.src, "table pattern %q matches no table", t.Name)
+			continue
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(t.Indexes)) {
+			at := t.at("indexes." + key)
+			if !slices.ContainsFunc(tables, func(st *schemaTable) bool { return st.hasIndex(key) }) {
+				if kindOf(key) != MatchExact {
+					problem(SeverityWarning, at, "index pattern %q matches no index of table %q", key, t.Name)
+				} else if len(tables) == 1 {
+					problem(SeverityError, at, "unknown index %q of table %q%s", key, tables[0].name, suggest(key, tables[0].indexes))
+				} else {
+					problem(SeverityError, at, "no table matching %q has an index %q", t.Name, key)
+				}
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(t.Lobs)) {
+			at := t.at("lobs." + key)
+			exact := kindOf(key) == MatchExact
+			var fields []schemaField
+			for _, st := range tables {
+				for _, f := range st.fieldsMatching(key) {
+					if f.lob || exact {
+						fields = append(fields, f)
+					}
+				}
+			}
+			switch {
+			case len(fields) == 0 && !exact:
+				problem(SeverityWarning, at, "LOB pattern %q matches no LOB field of table %q", key, t.Name)
+			case len(fields) == 0 && len(tables) == 1:
+				problem(SeverityError, at, "unknown field %q of table %q%s", key, tables[0].name, suggest(key, tables[0].lobNames()))
+			case len(fields) == 0:
+				problem(SeverityError, at, "no table matching %q has a field %q", t.Name, key)
+			default:
+				for _, f := range fields {
+					if !f.lob {
+						problem(SeverityError, at, "field %q of table %q is a %s field, not a BLOB or CLOB", f.name, f.table, f.dataType)
+					}
+				}
+			}
+		}
+	}
+
+	for _, g := range rules.Groups {
+		for _, member := range g.Tables {
+			if len(s.tables(member)) > 0 {
+				continue
+			}
+			if kindOf(member) == MatchExact {
+				problem(SeverityError, g.src, "group %q: unknown table %q%s", g.Name, member, suggest(member, s.tableNames()))
+			} else {
+				problem(SeverityWarning, g.src, "group %q: table pattern %q matches no table", g.Name, member)
+			}
+		}
+	}
+
+	res.Problems = append(res.Problems, dfProblems...)
+	return res, nil
+}
+
+// schema holds the tables, indexes and fields a .df adds.
+type schema struct {
+	list   []*schemaTable
+	byName map[string]*schemaTable // by lowercase name, the first of a name
+}
+
+// schemaTable is a table of a schema.
+type schemaTable struct {
+	name    string
+	indexes []string
+	fields  []schemaField
+}
+
+// schemaField is a field of a schema.
+type schemaField struct {
+	table    string
+	name     string
+	dataType string
+	lob      bool // a BLOB or CLOB, or a field with a LOB-AREA
+}
+
+// add records n if it adds a table, index or field.
+func (s *schema) add(n df.Node) {
+	switch n := n.(type) {
+	case *df.Table:
+		t := &schemaTable{name: n.Name}
+		s.list = append(s.list, t)
+		if key := strings.ToLower(n.Name); s.byName[key] == nil {
+			s.byName[key] = t
+		}
+	case *df.Index:
+		if t := s.table(n.Table); t != nil {
+			t.indexes = append(t.indexes, n.Name)
+		}
+	case *df.Field:
+		if t := s.table(n.Table); t != nil {
+			t.fields = append(t.fields, schemaField{table: t.name, name: n.Name, dataType: strings.ToLower(n.DataType), lob: n.IsLob() || n.HasAttr("LOB-AREA")})
+		}
+	}
+}
+
+// table returns the table called name, or nil.
+func (s *schema) table(name string) *schemaTable {
+	return s.byName[strings.ToLower(name)]
+}
+
+// tables returns the tables the rule name p matches.
+func (s *schema) tables(p string) []*schemaTable {
+	var out []*schemaTable
+	for _, t := range s.list {
+		if match(p, t.name) != NoMatch {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// tableNames returns the names of all tables.
+func (s *schema) tableNames() []string {
+	names := make([]string, len(s.list))
+	for i, t := range s.list {
+		names[i] = t.name
+	}
+	return names
+}
+
+// hasIndex reports whether t has an index the rule name p matches.
+func (t *schemaTable) hasIndex(p string) bool {
+	return slices.ContainsFunc(t.indexes, func(name string) bool { return match(p, name) != NoMatch })
+}
+
+// fieldsMatching returns the fields of t the rule name p matches.
+func (t *schemaTable) fieldsMatching(p string) []schemaField {
+	var out []schemaField
+	for _, f := range t.fields {
+		if match(p, f.name) != NoMatch {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// lobNames returns the names of the LOB fields of t.
+func (t *schemaTable) lobNames() []string {
+	var names []string
+	for _, f := range t.fields {
+		if f.lob {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// kindOf returns the kind of the rule name p.
+func kindOf(p string) MatchKind {
+	if cp, err := compilePattern(p); err == nil {
+		return cp.kind
+	}
+	return NoMatch
+}
+
+// suggest returns a "did you mean" hint with the candidate closest to name,
+// or "" if none is close enough to be a likely typo.
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", 0
+	for _, c := range candidates {
+		d := editDistance(strings.ToLower(name), strings.ToLower(c))
+		if best == "" || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" || bestDist > max(2, len(name)/3) {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean %q?", best)
+}
+
+// editDistance returns the Damerau-Levenshtein distance between a and b:
+// the number of inserted, deleted, changed and swapped adjacent runes.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
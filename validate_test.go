@@ -0,0 +1,62 @@
+package schemafixer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateRules(t *testing.T) {
+	rules := mustRules(t, `schemafixer:
+  defaults:
+    table: DataArea
+    lob: LobArea
+  tables:
+    - name: customer
+      indexes:
+        custnmu: CustIdx
+        "name*": NameIdx
+      lobs:
+        name: Lob
+    - name: itme
+      area: Items
+    - name: /^hist/
+      area: History
+    - name: Customer
+      area: Other
+  groups:
+    - name: sales
+      tables: [order, custmer]
+`)
+	src := "ADD TABLE \"Customer\"\n\n" +
+		"ADD FIELD \"Name\" OF \"Customer\" AS character \n\n" +
+		"ADD FIELD \"Photo\" OF \"Customer\" AS blob \n\n" +
+		"ADD INDEX \"CustNum\" ON \"Customer\" \n\n" +
+		"ADD TABLE \"Item\"\n\n" +
+		"ADD TABLE \"Order\"\n\n"
+
+	res, err := ValidateRules(context.Background(), strings.NewReader(src), rules, Options{})
+	if err != nil {
+		t.Fatalf("ValidateRules() error = %v", err)
+	}
+	var got []string
+	for _, p := range res.Problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`<rules>:2:1: error: the default index area is empty`,
+		`<rules>:8:1: error: unknown index "custnmu" of table "Customer"; did you mean "CustNum"?`,
+		`<rules>:9:1: warning: index pattern "name*" matches no index of table "customer"`,
+		`<rules>:11:1: error: field "Name" of table "Customer" is a character field, not a BLOB or CLOB`,
+		`<rules>:12:1: error: unknown table "itme"; did you mean "Item"?`,
+		`<rules>:14:1: warning: table pattern "/^hist/" matches no table`,
+		`<rules>:16:1: error: table "Customer" has another entry at <rules>:6, which takes precedence`,
+		`<rules>:19:1: error: group "sales": unknown table "custmer"; did you mean "Customer"?`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if res.OK() {
+		t.Error("OK() = true")
+	}
+}
@@ -0,0 +1,128 @@
+package schemafixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/bfv/schemafixer/df"
+)
+
+// VerifyResult describes the trailer of a .df and any problems found.
+type VerifyResult struct {
+	Codepage  string // cpstream setting, "" if absent
+	ByteCount int64  // declared byte count, -1 if absent
+	Actual    int64  // number of bytes up to and including the opening "." of the trailer
+
+	// Problems lists the diagnostics found, in the order they were found.
+	// They are not passed to Options.Report.
+	Problems []Diagnostic
+}
+
+// OK reports whether no errors were found. Warnings do not count.
+func (v *VerifyResult) OK() bool {
+	for _, p := range v.Problems {
+		if p.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify reads a .df from in and checks its trailer: the PSC block must be
+// present and complete, name a known codepage, and end with a byte count
+// that matches the content: as OpenEdge writes it, the offset of the PSC
+// line, just past the opening ".". A file that ends inside a quoted string or
+// without its trailer is reported as truncated. The structural checks every
+// command makes, such as statements that are not understood, are included
+// as well.
+func Verify(ctx context.Context, in io.Reader, opts Options) (*VerifyResult, error) {
+	res := &VerifyResult{ByteCount: -1}
+	opts.Report = func(d Diagnostic) { res.Problems = append(res.Problems, d) }
+	file := inputName(in)
+	problem := func(line int, format string, args ...any) {
+		opts.Report(Diagnostic{Severity: SeverityError, File: file, Pos: df.Pos{Line: line, Col: 1}, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	// Encoding to io.Discard counts the bytes exactly as they were read.
+	enc := df.NewEncoder(io.Discard)
+	var trailer *df.Trailer
+	var lastLine *df.Line
+	lf := 0 // lines ending in a bare LF up to the trailer's opening "."
+
+	err := decode(ctx, in, nil, newChecker(in, opts), func(n df.Node) error {
+		lines := n.Lines()
+		if len(lines) > 0 {
+			lastLine = lines[len(lines)-1]
+		}
+		if t, ok := n.(*df.Trailer); ok {
+			trailer = t
+			if err := enc.EncodeLines(lines[:1]); err != nil {
+				return err
+			}
+			res.Actual = enc.Written()
+			lf += countLF(lines[:1])
+			return enc.EncodeLines(lines[1:])
+		}
+		lf += countLF(lines)
+		return enc.EncodeLines(lines)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if trailer == nil {
+		res.Actual = enc.Written()
+		problem(0, "missing trailer; the file may be truncated")
+		if lastLine != nil && lastLine.EOL == "" {
+			problem(lastLine.Num, "file does not end with a newline")
+		}
+		return res, nil
+	}
+
+	pos := trailer.Pos().Line
+	if !trailer.HasPSC() {
+		problem(pos, "trailer lacks the PSC marker")
+	}
+	res.Codepage = trailer.Codepage()
+	switch {
+	case res.Codepage == "":
+		problem(pos, "trailer has no cpstream setting")
+	case !df.KnownCodepage(res.Codepage):
+		problem(pos, "unknown codepage %q", res.Codepage)
+	}
+	if !trailer.Closed() {
+		problem(pos, "trailer is not closed by a \".\" line; the file may be truncated")
+	}
+
+	n, ok := trailer.ByteCount()
+	if !ok {
+		problem(lastLine.Num, "missing byte count; the file may be truncated")
+		return res, nil
+	}
+	res.ByteCount = n
+	switch {
+	case n == res.Actual:
+	case lf > 0 && n == res.Actual+int64(lf):
+		// The content is intact; only its line endings were converted, as
+		// git does on checkout.
+		opts.Report(Diagnostic{Severity: SeverityWarning, File: file, Pos: df.Pos{Line: lastLine.Num, Col: 1},
+			Msg: fmt.Sprintf("byte count %010d is the trailer offset with CRLF line endings; the file was converted to LF, where it is %d", n, res.Actual)})
+	default:
+		problem(lastLine.Num, "byte count %010d does not match the trailer offset %d", n, res.Actual)
+	}
+
+	opts.Logger.Debug().Str("codepage", res.Codepage).Int64("byteCount", n).Int64("actual", res.Actual).Msg("verify complete")
+	return res, nil
+}
+
+// countLF returns the number of lines that end in a bare LF.
+func countLF(lines []*df.Line) int {
+	n := 0
+	for _, l := range lines {
+		if l.EOL == "\n" {
+			n++
+		}
+	}
+	return n
+}
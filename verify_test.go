@@ -0,0 +1,89 @@
+package schemafixer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	body := strings.TrimSuffix(testDF, "0000000000\n")
+	valid := body + fmt.Sprintf("%010d\n", trailerOffset(body))
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "valid", input: valid},
+		{name: "stale byte count", input: testDF, want: []string{"byte count 0000000000 does not match"}},
+		{name: "missing byte count", input: body, want: []string{"missing byte count"}},
+		{
+			name:  "unknown codepage",
+			input: strings.Replace(valid, "cpstream=UTF-8", "cpstream=EBCDIC", 1),
+			want:  []string{`unknown codepage "EBCDIC"`},
+		},
+		{
+			name:  "truncated in a string",
+			input: "ADD TABLE \"Item\"\n  DESCRIPTION \"cut off",
+			want:  []string{"unterminated quoted string", "missing trailer", "does not end with a newline"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Verify(context.Background(), strings.NewReader(tt.input), Options{})
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if len(res.Problems) != len(tt.want) {
+				t.Fatalf("got problems %+v, want %d", res.Problems, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(res.Problems[i].Msg, w) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, res.Problems[i].Msg, w)
+				}
+			}
+			if res.OK() != (len(tt.want) == 0) {
+				t.Errorf("OK() = %v", res.OK())
+			}
+		})
+	}
+}
+
+func TestVerify_Dump(t *testing.T) {
+	// sports2020.df as the Data Dictionary dumps it, with CRLF line endings.
+	data, err := os.ReadFile("schema/sports2020.df")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := strings.ReplaceAll(string(data), "\n", "\r\n")
+	res, err := Verify(context.Background(), strings.NewReader(src), Options{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !res.OK() || res.ByteCount != 60748 || res.Actual != 60748 {
+		t.Errorf("Verify() = %+v, want a byte count of 60748 that matches", res)
+	}
+
+	// The file as checked out, with LF line endings, only gets a warning.
+	res, err = Verify(context.Background(), bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !res.OK() || len(res.Problems) != 1 || !strings.Contains(res.Problems[0].Msg, "with CRLF line endings") {
+		t.Errorf("Verify() of the LF file = %+v, want a warning about CRLF", res.Problems)
+	}
+
+	// Applying rules that keep every area regenerates the same count.
+	rules := mustRules(t, "schemafixer:\n  version: 1.0\n  defaults:\n    table: \"@keep\"\n    index: \"@keep\"\n    lob: \"@keep\"\n")
+	var out bytes.Buffer
+	if _, err := Apply(context.Background(), strings.NewReader(src), rules, &out, Options{}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if out.String() != src {
+		t.Error("Apply() keeping every area changed the dump")
+	}
+}
@@ -0,0 +1,238 @@
+package schemafixer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ── Rules format versions ─────────────────────────────────────────────────────
+//
+// The version key of a rules file is the version of its format. A file of a
+// newer major version than RulesVersion is rejected, since it may mean
+// something this package would silently misread; a newer minor version only
+// adds keys, which are reported as unknown if the file uses them. Older
+// files are upgraded when they are read, by the steps in migrations, and
+// MigrateRules rewrites them in the current format. A file without a
+// version is read as the current version.
+//
+// Version 1.0 has defaults.table, index and lob, and tables with an area,
+// indexes and lobs. Version 1.1 adds the other keys: the per-kind defaults,
+// indexArea and lobArea, groups, conditional rules, areaMap and mapOrder,
+// areas, environments, extends and include. It also reads table,
+// index and field names as patterns and areas as templates.
+
+// RulesVersion is the version of the rules format this package reads and
+// writes.
+const RulesVersion = 1.1
+
+// migration upgrades rules of an older version to version to. rules
+// upgrades decoded rules, and node a rules file, given its schemafixer
+// mapping, keeping its comments. Either may be nil when the step only
+// changes what a version means. The version itself is set by the caller.
+type migration struct {
+	to    float64
+	desc  string
+	rules func(*SchemaFixerRules)
+	node  func(sf *yaml.Node)
+}
+
+// migrations are the upgrade steps, oldest first.
+var migrations = []migration{
+	{
+		to:    1.1,
+		desc:  "areas with {{ or ${, which are templates from 1.1 on, are quoted to keep their meaning",
+		rules: quoteAreas,
+		node:  quoteAreaNodes,
+	},
+}
+
+// MigrateResult describes what MigrateRules did.
+type MigrateResult struct {
+	From, To float64
+
+	// Steps describes each upgrade step applied, oldest first. It is empty
+	// for a file that is already current.
+	Steps []string
+}
+
+// MigrateRules reads a rules file from in and writes it to out in the
+// current format, keeping its comments. A file that is already current is
+// written unchanged. Files it extends or includes are not followed; migrate
+// them one by one.
+func MigrateRules(in io.Reader, out io.Writer) (*MigrateResult, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	name := inputName(in)
+	if name == "" {
+		name = "<rules>"
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	version, err := checkVersion(&doc, name)
+	if err != nil {
+		return nil, err
+	}
+	res := &MigrateResult{From: version, To: max(version, RulesVersion)}
+	_, sf := mapEntry(docRoot(&doc), "schemafixer")
+	if sf != nil && version == 0 {
+		setVersion(sf, RulesVersion)
+		res.Steps = append(res.Steps, fmt.Sprintf("unversioned → %s: the version is set; files without one are read as the current version", formatVersion(RulesVersion)))
+		version = RulesVersion
+	}
+	for _, m := range migrations {
+		if version >= m.to || sf == nil {
+			continue
+		}
+		if m.node != nil {
+			m.node(sf)
+		}
+		setVersion(sf, m.to)
+		res.Steps = append(res.Steps, fmt.Sprintf("%s → %s: %s", formatVersion(version), formatVersion(m.to), m.desc))
+		version = m.to
+	}
+	if len(res.Steps) == 0 {
+		_, err := out.Write(data)
+		return res, err
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b.Bytes()))
+	dec.KnownFields(true)
+	if err := dec.Decode(new(RulesFile)); err != nil {
+		return nil, decodeError(name+" (migrated)", err)
+	}
+	_, err = out.Write(b.Bytes())
+	return res, err
+}
+
+// upgrade brings r, read from a file of its version, to RulesVersion.
+func (r *SchemaFixerRules) upgrade() {
+	if r.Version == 0 {
+		r.Version = RulesVersion
+		return
+	}
+	for _, m := range migrations {
+		if r.Version >= m.to {
+			continue
+		}
+		if m.rules != nil {
+			m.rules(r)
+		}
+		r.Version = m.to
+	}
+}
+
+// checkVersion returns the version of the rules file doc named name, 0 if
+// it has none, and an error if its major version is newer than
+// RulesVersion.
+func checkVersion(doc *yaml.Node, name string) (float64, error) {
+	_, sf := mapEntry(docRoot(doc), "schemafixer")
+	k, v := mapEntry(sf, "version")
+	if v == nil || v.Value == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseFloat(v.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: version %q is not a number", source{name, k.Line}, v.Value)
+	}
+	if math.Floor(version) > math.Floor(RulesVersion) {
+		return 0, fmt.Errorf("%s: version %s: the rules are for a newer schemafixer, which reads up to version %s; upgrade schemafixer", source{name, k.Line}, v.Value, formatVersion(RulesVersion))
+	}
+	return version, nil
+}
+
+// docRoot returns the top mapping of the document doc, or nil.
+func docRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return nil
+}
+
+// setVersion sets the version key of the schemafixer mapping sf, adding it
+// as the first key if it is missing.
+func setVersion(sf *yaml.Node, version float64) {
+	if _, v := mapEntry(sf, "version"); v != nil {
+		v.Tag, v.Value, v.Style = "!!float", formatVersion(version), 0
+		return
+	}
+	sf.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+		{Kind: yaml.ScalarNode, Tag: "!!float", Value: formatVersion(version)},
+	}, sf.Content...)
+}
+
+// quoteAreas quotes the areas of rules of version 1.0 that would be read as
+// templates; see quoteTemplate. Only the keys of version 1.0 hold such
+// areas.
+func quoteAreas(r *SchemaFixerRules) {
+	d := &r.Defaults
+	for _, area := range []*string{&d.Table, &d.Index, &d.Lob} {
+		*area = quoteTemplate(*area)
+	}
+	for i := range r.Tables {
+		t := &r.Tables[i]
+		t.Area = quoteTemplate(t.Area)
+		for k, area := range t.Indexes {
+			t.Indexes[k] = quoteTemplate(area)
+		}
+		for k, area := range t.Lobs {
+			t.Lobs[k] = quoteTemplate(area)
+		}
+	}
+}
+
+// quoteAreaNodes is quoteAreas for the schemafixer mapping sf of a rules
+// file.
+func quoteAreaNodes(sf *yaml.Node) {
+	quote := func(n *yaml.Node) {
+		if n != nil && n.Kind == yaml.ScalarNode && isTemplate(n.Value) {
+			n.Value, n.Style = quoteTemplate(n.Value), yaml.SingleQuotedStyle
+		}
+	}
+	_, defaults := mapEntry(sf, "defaults")
+	for _, key := range []string{"table", "index", "lob"} {
+		_, v := mapEntry(defaults, key)
+		quote(v)
+	}
+	_, tables := mapEntry(sf, "tables")
+	if tables == nil || tables.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, t := range tables.Content {
+		_, area := mapEntry(t, "area")
+		quote(area)
+		for _, key := range []string{"indexes", "lobs"} {
+			if _, m := mapEntry(t, key); m != nil && m.Kind == yaml.MappingNode {
+				for i := 1; i < len(m.Content); i += 2 {
+					quote(m.Content[i])
+				}
+			}
+		}
+	}
+}
+
+// formatVersion returns version the way rules files write it, e.g. "1.0".
+func formatVersion(version float64) string {
+	if version == math.Trunc(version) {
+		return strconv.FormatFloat(version, 'f', 1, 64)
+	}
+	return strconv.FormatFloat(version, 'f', -1, 64)
+}
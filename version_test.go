@@ -0,0 +1,108 @@
+package schemafixer
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadRules_Version(t *testing.T) {
+	rf, err := ReadRules(strings.NewReader("schemafixer:\n  defaults:\n    table: DataArea\n"))
+	if err != nil {
+		t.Fatalf("ReadRules() error = %v", err)
+	}
+	if rf.SchemaFixer.Version != RulesVersion {
+		t.Errorf("unversioned rules upgraded to version %v, want %v", rf.SchemaFixer.Version, RulesVersion)
+	}
+
+	for src, want := range map[string]string{
+		"schemafixer:\n  version: 2.0\n":               `<rules>:2: version 2.0: the rules are for a newer schemafixer, which reads up to version 1.1; upgrade schemafixer`,
+		"schemafixer:\n  version: one\n":               `<rules>:2: version "one" is not a number`,
+		"schemafixer:\n  version: 1.9\n  zones: [A]\n": "<rules>:3: unknown key \"zones\"\n(the rules are version 1.9; this schemafixer reads up to 1.1)",
+	} {
+		if _, err := ReadRules(strings.NewReader(src)); err == nil || err.Error() != want {
+			t.Errorf("ReadRules() error = %v\nwant %s", err, want)
+		}
+	}
+
+	// Version 1.0 read areas as they are written; 1.1 expands templates.
+	for version, want := range map[string]string{"1.0": "${SITE}_{{.Table}}", "1.1": "east_Item"} {
+		rules := mustRules(t, "schemafixer:\n  version: "+version+"\n  defaults:\n    table: '${SITE}_{{.Table}}'\n")
+		rules.Vars = map[string]string{"SITE": "east"}
+		if got := areaOf(t)(rules.TableAreaFor(&TableInfo{Name: "Item"})); got != want {
+			t.Errorf("version %s: TableAreaFor() = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestMigrateRules(t *testing.T) {
+	src := `# Production areas
+schemafixer:
+  version: 1.0
+  defaults:
+    table: DataArea # most tables
+  tables:
+    - name: customer
+      area: Cust{Data} # braces are part of the name
+      indexes:
+        custnum: CustIdx
+`
+	var out strings.Builder
+	res, err := MigrateRules(strings.NewReader(src), &out)
+	if err != nil {
+		t.Fatalf("MigrateRules() error = %v", err)
+	}
+	want := `# Production areas
+schemafixer:
+  version: 1.1
+  defaults:
+    table: DataArea # most tables
+  tables:
+    - name: customer
+      area: Cust{Data} # braces are part of the name
+      indexes:
+        custnum: CustIdx
+`
+	if out.String() != want {
+		t.Errorf("MigrateRules() wrote:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if res.From != 1.0 || res.To != RulesVersion || len(res.Steps) != 1 {
+		t.Errorf("MigrateRules() = %+v", res)
+	}
+
+	// An area that version 1.1 reads as a template is quoted.
+	src = strings.Replace(src, "Cust{Data}", "Cust{{Data}}", 1)
+	out.Reset()
+	if _, err := MigrateRules(strings.NewReader(src), &out); err != nil {
+		t.Fatalf("MigrateRules() error = %v", err)
+	}
+	want = strings.Replace(want, "Cust{Data}", `'Cust{{"{"}}{{"{"}}Data}}'`, 1)
+	if out.String() != want {
+		t.Errorf("MigrateRules() wrote:\n%s\nwant:\n%s", out.String(), want)
+	}
+	migrated, err := ReadRules(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("ReadRules() of the migrated rules error = %v", err)
+	}
+	if got := areaOf(t)(migrated.SchemaFixer.TableAreaFor(&TableInfo{Name: "customer"})); got != "Cust{{Data}}" {
+		t.Errorf("migrated area = %q, want Cust{{Data}}", got)
+	}
+
+	// An unversioned file gets the current version.
+	out.Reset()
+	if res, err := MigrateRules(strings.NewReader("schemafixer:\n  defaults: {table: '{{.Table}}'}\n"), &out); err != nil || len(res.Steps) != 1 ||
+		out.String() != "schemafixer:\n  version: 1.1\n  defaults: {table: '{{.Table}}'}\n" {
+		t.Errorf("MigrateRules(unversioned) = %+v, %v, wrote %q", res, err, out.String())
+	}
+
+	// A current file is written as it is.
+	current := "schemafixer:\n  version: 1.1\n  defaults:   {table: DataArea}\n"
+	out.Reset()
+	if res, err := MigrateRules(strings.NewReader(current), &out); err != nil || len(res.Steps) != 0 || out.String() != current {
+		t.Errorf("MigrateRules(current) = %+v, %v, wrote %q", res, err, out.String())
+	}
+
+	if _, err := MigrateRules(strings.NewReader("schemafixer:\n  version: 3\n"), io.Discard); err == nil {
+		t.Error("MigrateRules() accepted version 3")
+	}
+}